@@ -0,0 +1,33 @@
+// render renders a scene file, see package scene for the format
+//
+//	render scenes/teapot.json
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/deosjr/GRayT/src/render"
+	"github.com/deosjr/GenGeo/scene"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: render scene.json")
+		os.Exit(2)
+	}
+	d, err := scene.Load(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("Creating scene...")
+	params, err := d.Build()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("Rendering...")
+	film := render.Render(params)
+	film.SaveAsPNG(d.Render.Output)
+}
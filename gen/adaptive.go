@@ -0,0 +1,329 @@
+package gen
+
+import (
+	"math"
+	"sort"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// Tolerance drives adaptive tessellation of a parametric surface:
+// a region is subdivided until it is within both tolerances.
+// a tolerance of 0 is ignored
+type Tolerance struct {
+	// max distance between the surface and the triangles approximating it
+	ChordalError float64
+	// max angle in radians between normals within a triangle
+	NormalAngle float64
+	// max levels of subdivision, defaults to 8
+	MaxDepth int
+}
+
+const (
+	adaptiveMinDepth     = 1
+	adaptiveDefaultDepth = 8
+)
+
+func (tol Tolerance) maxDepth() int {
+	if tol.MaxDepth <= 0 {
+		return adaptiveDefaultDepth
+	}
+	return tol.MaxDepth
+}
+
+func (tol Tolerance) chordalExceeded(actual, approx m.Vector) bool {
+	if tol.ChordalError == 0 {
+		return false
+	}
+	return float64(m.VectorFromTo(actual, approx).Length()) > tol.ChordalError
+}
+
+func (tol Tolerance) angleExceeded(a, b m.Vector) bool {
+	if tol.NormalAngle == 0 {
+		return false
+	}
+	cos := float64(a.Normalize().Dot(b.Normalize()))
+	return math.Acos(math.Max(-1, math.Min(1, cos))) > tol.NormalAngle
+}
+
+// adaptive tessellation of one surface over a quadtree in (u,v).
+// parameters are kept as integers on a grid of res+1 points per direction,
+// fine enough that the smallest cells still have an integer center.
+// the patch border is subdivided separately based only on the border curve,
+// so two patches sharing a border curve agree on its polyline;
+// quadtree vertices on the border that are not on that polyline are
+// moved onto it, which keeps neighbouring patches crack-free
+type adaptiveTessellation struct {
+	surface normalSurface
+	tol     Tolerance
+	res     int
+	leaves  [][4]int
+	// vertex positions along each horizontal line (by v) and vertical line (by u)
+	rows map[int]map[int]bool
+	cols map[int]map[int]bool
+	// subdivision of the border: bottom, right, top, left
+	border [4][]int
+	// evaluated points along the border, keyed as vertex keys
+	borderPoints map[int]m.Vector
+}
+
+func newAdaptiveTessellation(s normalSurface, tol Tolerance) *adaptiveTessellation {
+	a := &adaptiveTessellation{
+		surface:      s,
+		tol:          tol,
+		res:          1 << uint(tol.maxDepth()+1),
+		rows:         map[int]map[int]bool{},
+		cols:         map[int]map[int]bool{},
+		borderPoints: map[int]m.Vector{},
+	}
+	a.subdivideBorder()
+	a.subdivideCell(0, a.res, 0, a.res, 0)
+	return a
+}
+
+func (a *adaptiveTessellation) param(i int) float64 {
+	return float64(i) / float64(a.res)
+}
+
+func (a *adaptiveTessellation) evaluate(iu, iv int) m.Vector {
+	return a.surface.Evaluate(a.param(iu), a.param(iv))
+}
+
+func (a *adaptiveTessellation) key(iu, iv int) int {
+	return iv*(a.res+1) + iu
+}
+
+func (a *adaptiveTessellation) addVertex(iu, iv int) {
+	if a.rows[iv] == nil {
+		a.rows[iv] = map[int]bool{}
+	}
+	if a.cols[iu] == nil {
+		a.cols[iu] = map[int]bool{}
+	}
+	a.rows[iv][iu] = true
+	a.cols[iu][iv] = true
+}
+
+// borderFunc maps an integer parameter along border b to (iu, iv)
+// borders are numbered bottom, right, top, left
+func (a *adaptiveTessellation) borderFunc(b int) func(i int) (int, int) {
+	r := a.res
+	switch b {
+	case 0:
+		return func(i int) (int, int) { return i, 0 }
+	case 1:
+		return func(i int) (int, int) { return r, i }
+	case 2:
+		return func(i int) (int, int) { return i, r }
+	}
+	return func(i int) (int, int) { return 0, i }
+}
+
+func (a *adaptiveTessellation) subdivideBorder() {
+	for b := 0; b < 4; b++ {
+		set := map[int]bool{}
+		a.subdivideEdge(a.borderFunc(b), 0, a.res, 0, set)
+		for i := range set {
+			a.addBorderPoint(b, i)
+		}
+	}
+}
+
+// addBorderPoint adds a vertex at i along border b to the border polyline
+func (a *adaptiveTessellation) addBorderPoint(b, i int) {
+	line := a.border[b]
+	n := sort.SearchInts(line, i)
+	if n < len(line) && line[n] == i {
+		return
+	}
+	line = append(line, 0)
+	copy(line[n+1:], line[n:])
+	line[n] = i
+	a.border[b] = line
+	iu, iv := a.borderFunc(b)(i)
+	a.addVertex(iu, iv)
+	a.borderPoints[a.key(iu, iv)] = a.evaluate(iu, iv)
+}
+
+// subdivideEdge recursively splits the border curve between i0 and i1,
+// using only points on the curve itself to decide
+func (a *adaptiveTessellation) subdivideEdge(f func(i int) (int, int), i0, i1, depth int, set map[int]bool) {
+	mid := (i0 + i1) / 2
+	if depth < adaptiveMinDepth || (depth < a.tol.maxDepth() && a.edgeCurved(f, i0, mid, i1)) {
+		a.subdivideEdge(f, i0, mid, depth+1, set)
+		a.subdivideEdge(f, mid, i1, depth+1, set)
+		return
+	}
+	set[i0], set[i1] = true, true
+}
+
+func (a *adaptiveTessellation) edgeCurved(f func(i int) (int, int), i0, mid, i1 int) bool {
+	p0, pm, p1 := a.evaluate(f(i0)), a.evaluate(f(mid)), a.evaluate(f(i1))
+	if a.tol.chordalExceeded(pm, p0.Add(p1).Times(0.5)) {
+		return true
+	}
+	return a.tol.angleExceeded(m.VectorFromTo(p0, pm), m.VectorFromTo(pm, p1))
+}
+
+func (a *adaptiveTessellation) subdivideCell(iu0, iu1, iv0, iv1, depth int) {
+	if depth < adaptiveMinDepth || (depth < a.tol.maxDepth() && a.cellCurved(iu0, iu1, iv0, iv1)) {
+		um, vm := (iu0+iu1)/2, (iv0+iv1)/2
+		a.subdivideCell(iu0, um, iv0, vm, depth+1)
+		a.subdivideCell(um, iu1, iv0, vm, depth+1)
+		a.subdivideCell(iu0, um, vm, iv1, depth+1)
+		a.subdivideCell(um, iu1, vm, iv1, depth+1)
+		return
+	}
+	a.leaves = append(a.leaves, [4]int{iu0, iu1, iv0, iv1})
+	a.addVertex(iu0, iv0)
+	a.addVertex(iu1, iv0)
+	a.addVertex(iu0, iv1)
+	a.addVertex(iu1, iv1)
+}
+
+// a cell is curved if its center or edge midpoints are too far
+// from the bilinear approximation given by its corners,
+// or the normals at its corners deviate too far from the one at its center
+func (a *adaptiveTessellation) cellCurved(iu0, iu1, iv0, iv1 int) bool {
+	um, vm := (iu0+iu1)/2, (iv0+iv1)/2
+	p00, p10 := a.evaluate(iu0, iv0), a.evaluate(iu1, iv0)
+	p01, p11 := a.evaluate(iu0, iv1), a.evaluate(iu1, iv1)
+	bilinear := p00.Add(p10).Add(p01).Add(p11).Times(0.25)
+	if a.tol.chordalExceeded(a.evaluate(um, vm), bilinear) {
+		return true
+	}
+	for _, e := range []struct {
+		iu, iv int
+		p, q   m.Vector
+	}{
+		{um, iv0, p00, p10},
+		{iu1, vm, p10, p11},
+		{um, iv1, p01, p11},
+		{iu0, vm, p00, p01},
+	} {
+		if a.tol.chordalExceeded(a.evaluate(e.iu, e.iv), e.p.Add(e.q).Times(0.5)) {
+			return true
+		}
+	}
+	center := a.surface.normal(a.param(um), a.param(vm))
+	for _, c := range [][2]int{{iu0, iv0}, {iu1, iv0}, {iu0, iv1}, {iu1, iv1}} {
+		if a.tol.angleExceeded(center, a.surface.normal(a.param(c[0]), a.param(c[1]))) {
+			return true
+		}
+	}
+	return false
+}
+
+// position returns the vertex position at (iu, iv),
+// snapping points on the patch border onto the border polyline
+func (a *adaptiveTessellation) position(iu, iv int) m.Vector {
+	if p, ok := a.borderPoints[a.key(iu, iv)]; ok {
+		return p
+	}
+	var b, i int
+	switch {
+	case iv == 0:
+		b, i = 0, iu
+	case iu == a.res:
+		b, i = 1, iv
+	case iv == a.res:
+		b, i = 2, iu
+	case iu == 0:
+		b, i = 3, iv
+	default:
+		return a.evaluate(iu, iv)
+	}
+	line, f := a.border[b], a.borderFunc(b)
+	n := sort.SearchInts(line, i)
+	lo, hi := line[n-1], line[n]
+	t := float32(i-lo) / float32(hi-lo)
+	plo, phi := a.borderPoints[a.key(f(lo))], a.borderPoints[a.key(f(hi))]
+	return plo.Add(m.VectorFromTo(plo, phi).Times(t))
+}
+
+func sortedLines(lines map[int]map[int]bool) map[int][]int {
+	sorted := map[int][]int{}
+	for k, line := range lines {
+		for i := range line {
+			sorted[k] = append(sorted[k], i)
+		}
+		sort.Ints(sorted[k])
+	}
+	return sorted
+}
+
+// positions on a sorted line between lo and hi inclusive
+func pointsBetween(line []int, lo, hi int) []int {
+	return line[sort.SearchInts(line, lo):sort.SearchInts(line, hi+1)]
+}
+
+// mesh triangulates each leaf of the quadtree. leaves whose edges
+// only hold their own corners are split in two triangles,
+// other leaves are fanned from their center point so they
+// connect to all vertices of their smaller neighbours
+func (a *adaptiveTessellation) mesh() Mesh {
+	mesh := Mesh{}
+	indices := map[int]int{}
+	vertex := func(iu, iv int) int {
+		k := a.key(iu, iv)
+		if i, ok := indices[k]; ok {
+			return i
+		}
+		u, v := a.param(iu), a.param(iv)
+		i := len(mesh.Vertices)
+		indices[k] = i
+		mesh.Vertices = append(mesh.Vertices, a.position(iu, iv))
+		mesh.Normals = append(mesh.Normals, a.surface.normal(u, v))
+		mesh.UVs = append(mesh.UVs, m.Vector{float32(u), float32(v), 0})
+		return i
+	}
+	rows, cols := sortedLines(a.rows), sortedLines(a.cols)
+	for _, l := range a.leaves {
+		iu0, iu1, iv0, iv1 := l[0], l[1], l[2], l[3]
+		// counterclockwise in (u,v) starting at (u0, v0)
+		polygon := [][2]int{}
+		for _, iu := range pointsBetween(rows[iv0], iu0, iu1-1) {
+			polygon = append(polygon, [2]int{iu, iv0})
+		}
+		for _, iv := range pointsBetween(cols[iu1], iv0, iv1-1) {
+			polygon = append(polygon, [2]int{iu1, iv})
+		}
+		top := pointsBetween(rows[iv1], iu0+1, iu1)
+		for i := len(top) - 1; i >= 0; i-- {
+			polygon = append(polygon, [2]int{top[i], iv1})
+		}
+		left := pointsBetween(cols[iu0], iv0+1, iv1)
+		for i := len(left) - 1; i >= 0; i-- {
+			polygon = append(polygon, [2]int{iu0, left[i]})
+		}
+		if len(polygon) == 4 {
+			p00, p10 := vertex(iu0, iv0), vertex(iu1, iv0)
+			p11, p01 := vertex(iu1, iv1), vertex(iu0, iv1)
+			mesh.Faces = append(mesh.Faces, [3]int{p00, p10, p11}, [3]int{p00, p11, p01})
+			continue
+		}
+		center := vertex((iu0+iu1)/2, (iv0+iv1)/2)
+		for i, p := range polygon {
+			q := polygon[(i+1)%len(polygon)]
+			mesh.Faces = append(mesh.Faces, [3]int{center, vertex(p[0], p[1]), vertex(q[0], q[1])})
+		}
+	}
+	return mesh
+}
+
+// adaptiveMesh tessellates a surface into a mesh with normals and uvs,
+// using more triangles where the surface curves more
+func adaptiveMesh(s normalSurface, tol Tolerance) Mesh {
+	return newAdaptiveTessellation(s, tol).mesh()
+}
+
+// AdaptiveSurface is a surface that can also be tessellated adaptively;
+// bezier patches and nurbs surfaces implement it
+type AdaptiveSurface interface {
+	ParametricSurface
+	TriangulateAdaptive(tol Tolerance, mat m.Material) m.Object
+}
+
+func triangulateAdaptive(s normalSurface, tol Tolerance, mat m.Material) m.Object {
+	return adaptiveMesh(s, tol).Object(mat)
+}
@@ -0,0 +1,146 @@
+package gen
+
+import (
+	"math"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+var (
+	_ AdaptiveSurface = bicubicBezierPatch{}
+	_ AdaptiveSurface = bezierPatch{}
+	_ AdaptiveSurface = nurbsSurface{}
+)
+
+func TestAdaptiveFlatSurface(t *testing.T) {
+	patch := flatPatch(t, 0)
+	mesh := adaptiveMesh(patch, Tolerance{ChordalError: 0.01, NormalAngle: 0.1})
+	// minimum depth only: 4 cells of 2 triangles each
+	if len(mesh.Faces) != 8 {
+		t.Errorf("got %d faces want 8", len(mesh.Faces))
+	}
+	for i, f := range mesh.Faces {
+		p0, p1, p2 := mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]]
+		n := m.VectorFromTo(p0, p1).Cross(m.VectorFromTo(p0, p2)).Normalize()
+		if !approxVector(n, patch.normal(0.5, 0.5)) {
+			t.Errorf("%d): face normal %v does not match surface", i, n)
+		}
+	}
+}
+
+func TestAdaptiveRefinesCurvature(t *testing.T) {
+	sphere := NewNURBSSphere(m.Vector{}, 1)
+	coarse := adaptiveMesh(sphere, Tolerance{ChordalError: 0.05})
+	fine := adaptiveMesh(sphere, Tolerance{ChordalError: 0.001})
+	if len(fine.Faces) <= len(coarse.Faces) {
+		t.Errorf("finer tolerance gave %d faces, coarser %d", len(fine.Faces), len(coarse.Faces))
+	}
+	for i, f := range fine.Faces {
+		p0, p1, p2 := fine.Vertices[f[0]], fine.Vertices[f[1]], fine.Vertices[f[2]]
+		centroid := p0.Add(p1).Add(p2).Times(1.0 / 3.0)
+		if d := 1 - centroid.Length(); d > 0.001*4 {
+			t.Errorf("%d): triangle centroid %f from surface", i, d)
+		}
+	}
+}
+
+// two patches sharing a border, one much more curved than the other:
+// every vertex along the shared border should lie on the other patch's border
+func TestAdaptiveCrackFree(t *testing.T) {
+	bumpy := flatPoints(3)
+	for i := range bumpy {
+		if i%4 != 0 {
+			bumpy[i].Z = float32(math.Sin(float64(i))) * 3
+		}
+	}
+	a := flatPatch(t, 0)
+	b, _ := NewBicubicBezierPatch(bumpy)
+	tol := Tolerance{ChordalError: 0.01, NormalAngle: 0.2, MaxDepth: 6}
+	meshA, meshB := adaptiveMesh(a, tol), adaptiveMesh(b, tol)
+	borderA := borderPolyline(meshA, func(uv m.Vector) bool { return uv.X == 1 })
+	borderB := borderPolyline(meshB, func(uv m.Vector) bool { return uv.X == 0 })
+	if len(borderA) < 2 || len(borderB) < 2 {
+		t.Fatalf("no shared border found")
+	}
+	for _, mesh := range []Mesh{meshA, meshB} {
+		checkNoTJunctions(t, mesh)
+	}
+	for _, p := range borderA {
+		if d := distanceToPolyline(p, borderB); d > 1e-4 {
+			t.Errorf("vertex %v of a is %f from border of b", p, d)
+		}
+	}
+	for _, p := range borderB {
+		if d := distanceToPolyline(p, borderA); d > 1e-4 {
+			t.Errorf("vertex %v of b is %f from border of a", p, d)
+		}
+	}
+}
+
+// border vertices sorted by v
+func borderPolyline(mesh Mesh, onBorder func(uv m.Vector) bool) []m.Vector {
+	byV := map[float32]m.Vector{}
+	vs := []float32{}
+	for i, uv := range mesh.UVs {
+		if onBorder(uv) {
+			byV[uv.Y] = mesh.Vertices[i]
+			vs = append(vs, uv.Y)
+		}
+	}
+	for i := 1; i < len(vs); i++ {
+		for j := i; j > 0 && vs[j] < vs[j-1]; j-- {
+			vs[j], vs[j-1] = vs[j-1], vs[j]
+		}
+	}
+	line := make([]m.Vector, len(vs))
+	for i, v := range vs {
+		line[i] = byV[v]
+	}
+	return line
+}
+
+func distanceToPolyline(p m.Vector, line []m.Vector) float32 {
+	min := float32(math.Inf(1))
+	for i := 0; i < len(line)-1; i++ {
+		a, b := line[i], line[i+1]
+		ab := m.VectorFromTo(a, b)
+		t := m.VectorFromTo(a, p).Dot(ab) / ab.Dot(ab)
+		if t < 0 {
+			t = 0
+		}
+		if t > 1 {
+			t = 1
+		}
+		if d := m.VectorFromTo(a.Add(ab.Times(t)), p).Length(); d < min {
+			min = d
+		}
+	}
+	return min
+}
+
+// within a patch every edge is shared by two faces, except on the border
+func checkNoTJunctions(t *testing.T, mesh Mesh) {
+	edges := map[[2]int]int{}
+	for _, f := range mesh.Faces {
+		for i := 0; i < 3; i++ {
+			a, b := f[i], f[(i+1)%3]
+			if a > b {
+				a, b = b, a
+			}
+			edges[[2]int{a, b}]++
+		}
+	}
+	onBorder := func(uv m.Vector) bool {
+		return uv.X == 0 || uv.X == 1 || uv.Y == 0 || uv.Y == 1
+	}
+	for e, n := range edges {
+		if n == 2 {
+			continue
+		}
+		if n == 1 && onBorder(mesh.UVs[e[0]]) && onBorder(mesh.UVs[e[1]]) {
+			continue
+		}
+		t.Errorf("edge %v between %v and %v used by %d faces", e, mesh.UVs[e[0]], mesh.UVs[e[1]], n)
+	}
+}
@@ -1,6 +1,7 @@
 package gen
 
 import (
+	"fmt"
 	"math"
 
 	m "github.com/deosjr/GRayT/src/model"
@@ -21,9 +22,91 @@ func NewBezierCurve(points []m.Vector) bezierCurve {
 	}
 }
 
+// evaluates a bezier curve of any degree using de Casteljau's algorithm:
+// repeated linear interpolation between consecutive control points
 func bezierFunc(t float64, points []m.Vector) m.Vector {
-	//TODO, dont feel like implementing binomials etc right now
-	return m.Vector{}
+	if len(points) == 0 {
+		return m.Vector{}
+	}
+	p := make([]m.Vector, len(points))
+	copy(p, points)
+	for n := len(p) - 1; n > 0; n-- {
+		for i := 0; i < n; i++ {
+			p[i] = linearBezierFunc(t, p[i], p[i+1])
+		}
+	}
+	return p[0]
+}
+
+// derivative of a degree n bezier curve is a degree n-1 bezier curve
+// with control points n*(p[i+1]-p[i])
+// derivative of a constant (degree 0) curve is the zero vector
+func (b bezierCurve) Derivative() ParametricFunction {
+	return NewBezierCurve(derivativeControlPoints(b.controlPoints))
+}
+
+func (b bezierCurve) SecondDerivative() ParametricFunction {
+	return b.Derivative().(bezierCurve).Derivative()
+}
+
+func derivativeControlPoints(points []m.Vector) []m.Vector {
+	n := len(points) - 1
+	if n < 1 {
+		return []m.Vector{{}}
+	}
+	newPoints := make([]m.Vector, n)
+	for i := 0; i < n; i++ {
+		newPoints[i] = m.VectorFromTo(points[i], points[i+1]).Times(float32(n))
+	}
+	return newPoints
+}
+
+// Split divides the curve at t into two curves of the same degree,
+// the first tracing [0,t] and the second [t,1] of the original
+func (b bezierCurve) Split(t float64) (bezierCurve, bezierCurve) {
+	left, right := splitControlPoints(t, b.controlPoints)
+	return NewBezierCurve(left), NewBezierCurve(right)
+}
+
+// Elevate returns the same curve described with one more control point
+func (b bezierCurve) Elevate() bezierCurve {
+	return NewBezierCurve(elevateControlPoints(b.controlPoints))
+}
+
+// de Casteljau's algorithm again, but keeping the intermediate points:
+// the first point of each level forms the left curve,
+// the last point of each level forms the right curve
+func splitControlPoints(t float64, points []m.Vector) ([]m.Vector, []m.Vector) {
+	n := len(points)
+	left, right := make([]m.Vector, n), make([]m.Vector, n)
+	p := make([]m.Vector, n)
+	copy(p, points)
+	for level := 0; level < n; level++ {
+		left[level] = p[0]
+		right[n-1-level] = p[n-1-level]
+		for i := 0; i < n-1-level; i++ {
+			p[i] = linearBezierFunc(t, p[i], p[i+1])
+		}
+	}
+	return left, right
+}
+
+// degree elevation from n to n+1:
+// q[i] = i/(n+1) * p[i-1] + (1 - i/(n+1)) * p[i]
+// a curve without control points stays empty
+func elevateControlPoints(points []m.Vector) []m.Vector {
+	if len(points) == 0 {
+		return nil
+	}
+	n := len(points) - 1
+	newPoints := make([]m.Vector, n+2)
+	newPoints[0] = points[0]
+	newPoints[n+1] = points[n]
+	for i := 1; i <= n; i++ {
+		alpha := float32(i) / float32(n+1)
+		newPoints[i] = points[i-1].Times(alpha).Add(points[i].Times(1 - alpha))
+	}
+	return newPoints
 }
 
 type cubicBezierCurve struct {
@@ -63,6 +146,16 @@ func (b cubicBezierCurve) SecondDerivative() ParametricFunction {
 	return b.Derivative().(quadraticBezierCurve).Derivative()
 }
 
+func (b cubicBezierCurve) Split(t float64) (cubicBezierCurve, cubicBezierCurve) {
+	left, right := splitControlPoints(t, b.bezierCurve.controlPoints)
+	return NewCubicBezierCurve(left[0], left[1], left[2], left[3]), NewCubicBezierCurve(right[0], right[1], right[2], right[3])
+}
+
+// there is no quartic type, so elevating a cubic returns a general bezier curve
+func (b cubicBezierCurve) Elevate() bezierCurve {
+	return b.bezierCurve.Elevate()
+}
+
 type quadraticBezierCurve struct {
 	bezierCurve
 }
@@ -95,6 +188,105 @@ func (b quadraticBezierCurve) Derivative() ParametricFunction {
 	return NewLinearBezierCurve(newPoint(p0, p1), newPoint(p1, p2))
 }
 
+func (b quadraticBezierCurve) Split(t float64) (quadraticBezierCurve, quadraticBezierCurve) {
+	left, right := splitControlPoints(t, b.bezierCurve.controlPoints)
+	return NewQuadraticBezierCurve(left[0], left[1], left[2]), NewQuadraticBezierCurve(right[0], right[1], right[2])
+}
+
+func (b quadraticBezierCurve) Elevate() cubicBezierCurve {
+	p := elevateControlPoints(b.bezierCurve.controlPoints)
+	return NewCubicBezierCurve(p[0], p[1], p[2], p[3])
+}
+
+// rational bezier curve, t in [0-1]
+// each control point has a weight pulling the curve towards it.
+// evaluated as a bezier curve in homogeneous coordinates (w*p, w)
+// projected back by dividing by w, which allows exact conic sections
+type rationalBezierCurve struct {
+	parametricFunction
+	controlPoints []m.Vector
+	weights       []float64
+}
+
+func newRationalBezierCurve(points []m.Vector, weights []float64) rationalBezierCurve {
+	c := rationalBezierCurve{
+		controlPoints: points,
+		weights:       weights,
+	}
+	c.parametricFunction = NewParametricFunction(func(t float64) m.Vector {
+		return c.derivatives(t, 0)[0]
+	})
+	return c
+}
+
+// derivatives returns the point on the curve and its first n derivatives wrt t
+func (c rationalBezierCurve) derivatives(t float64, n int) []m.Vector {
+	homogeneous := make([]m.Vector, len(c.controlPoints))
+	weights := make([]m.Vector, len(c.weights))
+	for i, p := range c.controlPoints {
+		homogeneous[i] = p.Times(float32(c.weights[i]))
+		// weights as a one dimensional bezier curve along x
+		weights[i] = m.Vector{float32(c.weights[i]), 0, 0}
+	}
+	aders := make([]m.Vector, n+1)
+	wders := make([]float64, n+1)
+	for k := 0; k <= n; k++ {
+		aders[k] = bezierFunc(t, homogeneous)
+		wders[k] = float64(bezierFunc(t, weights).X)
+		homogeneous = derivativeControlPoints(homogeneous)
+		weights = derivativeControlPoints(weights)
+	}
+	return rationalDerivatives(aders, wders)
+}
+
+func (c rationalBezierCurve) Derivative() ParametricFunction {
+	return NewParametricFunction(func(t float64) m.Vector {
+		return c.derivatives(t, 1)[1]
+	})
+}
+
+func (c rationalBezierCurve) SecondDerivative() ParametricFunction {
+	return NewParametricFunction(func(t float64) m.Vector {
+		return c.derivatives(t, 2)[2]
+	})
+}
+
+type rationalQuadraticBezierCurve struct {
+	rationalBezierCurve
+}
+
+// weights have to be positive, or the curve can divide by zero
+func NewRationalQuadraticBezierCurve(p0, p1, p2 m.Vector, w0, w1, w2 float64) (rationalQuadraticBezierCurve, error) {
+	weights := []float64{w0, w1, w2}
+	if err := validateWeights(3, weights); err != nil {
+		return rationalQuadraticBezierCurve{}, err
+	}
+	return rationalQuadraticBezierCurve{
+		newRationalBezierCurve([]m.Vector{p0, p1, p2}, weights),
+	}, nil
+}
+
+// NewConicArc returns a conic section from p0 to p2 with p1 as shoulder point:
+// an ellipse for w < 1, a parabola for w = 1 and a hyperbola for w > 1
+func NewConicArc(p0, p1, p2 m.Vector, w float64) (rationalQuadraticBezierCurve, error) {
+	return NewRationalQuadraticBezierCurve(p0, p1, p2, 1, w, 1)
+}
+
+type rationalCubicBezierCurve struct {
+	rationalBezierCurve
+}
+
+// weights have to be positive, or the curve can divide by zero
+func NewRationalCubicBezierCurve(p0, p1, p2, p3 m.Vector, w0, w1, w2, w3 float64) (rationalCubicBezierCurve, error) {
+	weights := []float64{w0, w1, w2, w3}
+	if err := validateWeights(4, weights); err != nil {
+		return rationalCubicBezierCurve{}, err
+	}
+	return rationalCubicBezierCurve{
+		newRationalBezierCurve([]m.Vector{p0, p1, p2, p3}, weights),
+	}, nil
+}
+
 type linearBezierCurve struct {
 	bezierCurve
 }
@@ -125,10 +317,22 @@ type bicubicBezierPatch struct {
 	controlPoints []m.Vector // len 16
 }
 
-func NewBicubicBezierPatch(points []m.Vector) bicubicBezierPatch {
+func NewBicubicBezierPatch(points []m.Vector) (bicubicBezierPatch, error) {
+	if len(points) != 16 {
+		return bicubicBezierPatch{}, fmt.Errorf("Invalid number of control points for bicubic patch: %d", len(points))
+	}
 	return bicubicBezierPatch{
 		controlPoints: points,
-	}
+	}, nil
+}
+
+func (b bicubicBezierPatch) Degree() (int, int) {
+	return 3, 3
+}
+
+// ControlPoints returns a copy of the 16 control points, 4 rows along u
+func (b bicubicBezierPatch) ControlPoints() []m.Vector {
+	return append([]m.Vector{}, b.controlPoints...)
 }
 
 func (b bicubicBezierPatch) Evaluate(u, v float64) m.Vector {
@@ -146,14 +350,7 @@ func (b bicubicBezierPatch) Evaluate(u, v float64) m.Vector {
 // samples is number of triangles in one dimension of the surface,
 // for samples*samples amount of triangles in total
 func (b bicubicBezierPatch) Triangulate(samples int, mat m.Material) m.Object {
-    vertices := make([]m.Vector, (samples+1)*(samples+1))
-    f64s := float64(samples)
-	for v:=0; v<=samples; v++ {
-		for u:=0; u<=samples; u++ {
-            vertices[v*(samples+1)+u] = b.Evaluate(float64(u)/f64s, float64(v)/f64s)
-        }
-    }
-    return m.NewGridTriangleMesh(samples, samples, vertices, nil, nil, mat)
+    return triangulateGrid(b, samples, mat)
 }
 
 func dUBezier(controlPoints []m.Vector, u64, v float64) m.Vector {
@@ -198,18 +395,102 @@ func (b bicubicBezierPatch) normal(u, v float64) m.Vector {
 }
 
 func (b bicubicBezierPatch) TriangulateWithNormalMapping(samples int, baseMat m.Material) m.Object {
-    mat := m.InterpolatedNormalMappingMaterial(baseMat)
-    vertices := make([]m.Vector, (samples+1)*(samples+1))
-    normals := make([]m.Vector, (samples+1)*(samples+1))
-    uvs := make([]m.Vector, (samples+1)*(samples+1))
-    f64s := float64(samples)
-	for intv:=0; intv<=samples; intv++ {
-		for intu:=0; intu<=samples; intu++ {
-            u, v := float64(intu)/f64s, float64(intv)/f64s
-            vertices[intv*(samples+1)+intu] = b.Evaluate(u, v)
-            normals[intv*(samples+1)+intu] = b.normal(u, v)
-            uvs[intv*(samples+1)+intu] = m.Vector{float32(u), float32(v), 0}
-        }
-    }
-    return m.NewGridTriangleMesh(samples, samples, vertices, normals, uvs, mat)
+    return triangulateGridWithNormalMapping(b, samples, baseMat)
+}
+
+// triangulate using as few triangles as possible while staying within tolerance
+func (b bicubicBezierPatch) TriangulateAdaptive(tol Tolerance, mat m.Material) m.Object {
+    return triangulateAdaptive(b, tol, mat)
+}
+
+// tensor product bezier patch of any degree in u and v
+// control points are stored row by row: degreeU+1 points along u
+// for each of degreeV+1 rows, same layout as bicubicBezierPatch
+type bezierPatch struct {
+	degreeU       int
+	degreeV       int
+	controlPoints []m.Vector
+}
+
+func NewBezierPatch(degreeU, degreeV int, points []m.Vector) (bezierPatch, error) {
+	if degreeU < 1 || degreeV < 1 {
+		return bezierPatch{}, fmt.Errorf("Invalid patch degree: %dx%d", degreeU, degreeV)
+	}
+	if len(points) != (degreeU+1)*(degreeV+1) {
+		return bezierPatch{}, fmt.Errorf("Invalid number of control points for %dx%d patch: %d", degreeU, degreeV, len(points))
+	}
+	return bezierPatch{
+		degreeU:       degreeU,
+		degreeV:       degreeV,
+		controlPoints: points,
+	}, nil
+}
+
+func (b bezierPatch) Degree() (int, int) {
+	return b.degreeU, b.degreeV
+}
+
+// ControlPoints returns a copy of the control points, row by row along u
+func (b bezierPatch) ControlPoints() []m.Vector {
+	return append([]m.Vector{}, b.controlPoints...)
+}
+
+func (b bezierPatch) row(j int) []m.Vector {
+	n := b.degreeU + 1
+	return b.controlPoints[j*n : (j+1)*n]
+}
+
+func (b bezierPatch) column(i int) []m.Vector {
+	n := b.degreeU + 1
+	column := make([]m.Vector, b.degreeV+1)
+	for j := range column {
+		column[j] = b.controlPoints[j*n+i]
+	}
+	return column
+}
+
+func (b bezierPatch) Evaluate(u, v float64) m.Vector {
+	p := make([]m.Vector, b.degreeV+1)
+	for j := range p {
+		p[j] = bezierFunc(u, b.row(j))
+	}
+	return bezierFunc(v, p)
+}
+
+// partial derivative wrt u: evaluate each column along v,
+// then take the derivative of the resulting curve along u
+func (b bezierPatch) dU(u, v float64) m.Vector {
+	p := make([]m.Vector, b.degreeU+1)
+	for i := range p {
+		p[i] = bezierFunc(v, b.column(i))
+	}
+	return bezierFunc(u, derivativeControlPoints(p))
+}
+
+func (b bezierPatch) dV(u, v float64) m.Vector {
+	p := make([]m.Vector, b.degreeV+1)
+	for j := range p {
+		p[j] = bezierFunc(u, b.row(j))
+	}
+	return bezierFunc(v, derivativeControlPoints(p))
+}
+
+func (b bezierPatch) normal(u, v float64) m.Vector {
+	return surfaceNormal(func(u, v float64) (m.Vector, m.Vector) {
+		return b.dU(u, v), b.dV(u, v)
+	}, u, v)
+}
+
+// samples is number of triangles in one dimension of the surface,
+// for samples*samples amount of triangles in total
+func (b bezierPatch) Triangulate(samples int, mat m.Material) m.Object {
+	return triangulateGrid(b, samples, mat)
+}
+
+func (b bezierPatch) TriangulateWithNormalMapping(samples int, baseMat m.Material) m.Object {
+	return triangulateGridWithNormalMapping(b, samples, baseMat)
+}
+
+func (b bezierPatch) TriangulateAdaptive(tol Tolerance, mat m.Material) m.Object {
+	return triangulateAdaptive(b, tol, mat)
 }
@@ -1,6 +1,7 @@
 package gen
 
 import (
+	"math"
 	"testing"
 
 	m "github.com/deosjr/GRayT/src/model"
@@ -39,3 +40,230 @@ func TestCubicBezier(t *testing.T) {
 		}
 	}
 }
+
+func TestBezierCurve(t *testing.T) {
+	p0, p1, p2, p3 := m.Vector{1, 2, 3}, m.Vector{4, -5, 6}, m.Vector{7, 8, -9}, m.Vector{10, 11, 12}
+	cubic := NewCubicBezierCurve(p0, p1, p2, p3)
+	bezier := NewBezierCurve([]m.Vector{p0, p1, p2, p3})
+	for _, tt := range []float64{0.0, 0.25, 0.5, 0.75, 1.0} {
+		if got, want := bezier.Vector(tt), cubic.Vector(tt); !compareVector(got, want) {
+			t.Errorf("t=%f: got %v want %v", tt, got, want)
+		}
+		if got, want := bezier.Derivative().Vector(tt), cubic.Derivative().Vector(tt); !compareVector(got, want) {
+			t.Errorf("t=%f: derivative got %v want %v", tt, got, want)
+		}
+		if got, want := bezier.SecondDerivative().Vector(tt), cubic.SecondDerivative().Vector(tt); !compareVector(got, want) {
+			t.Errorf("t=%f: second derivative got %v want %v", tt, got, want)
+		}
+	}
+}
+
+func TestBezierCurveHigherDegree(t *testing.T) {
+	points := []m.Vector{{0, 0, 0}, {1, 2, 0}, {2, -1, 1}, {3, 3, 0}, {4, 0, 2}, {5, 1, 0}}
+	bezier := NewBezierCurve(points)
+	for i, tt := range []struct {
+		t    float64
+		want m.Vector
+	}{
+		{t: 0.0, want: points[0]},
+		{t: 1.0, want: points[5]},
+		// control points are evenly spaced in x, so x(t) = 5t
+		{t: 0.5, want: m.Vector{2.5, 0.96875, 0.625}},
+	} {
+		got := bezier.Vector(tt.t)
+		if !compareVector(got, tt.want) {
+			t.Errorf("%d): got %v want %v", i, got, tt.want)
+		}
+	}
+	// end tangents point along the first and last control polygon legs
+	if got, want := bezier.Derivative().Vector(0), v(5, 10, 0); !compareVector(got, want) {
+		t.Errorf("derivative at 0: got %v want %v", got, want)
+	}
+	if got, want := bezier.Derivative().Vector(1), v(5, 5, -10); !compareVector(got, want) {
+		t.Errorf("derivative at 1: got %v want %v", got, want)
+	}
+}
+
+func TestBezierSplit(t *testing.T) {
+	cubic := NewCubicBezierCurve(m.Vector{0, 0, 0}, m.Vector{1, 3, 0}, m.Vector{3, 3, 1}, m.Vector{4, 0, 2})
+	quadratic := NewQuadraticBezierCurve(m.Vector{0, 0, 0}, m.Vector{2, 4, -2}, m.Vector{4, 0, 1})
+	general := NewBezierCurve([]m.Vector{{0, 0, 0}, {1, 2, 0}, {2, -1, 1}, {3, 3, 0}, {4, 0, 2}})
+	for _, split := range []float64{0.25, 0.5, 0.75} {
+		cl, cr := cubic.Split(split)
+		ql, qr := quadratic.Split(split)
+		gl, gr := general.Split(split)
+		for _, tt := range []struct {
+			name  string
+			curve ParametricFunction
+			left  ParametricFunction
+			right ParametricFunction
+		}{
+			{"cubic", cubic, cl, cr},
+			{"quadratic", quadratic, ql, qr},
+			{"general", general, gl, gr},
+		} {
+			for _, s := range []float64{0.0, 0.25, 0.5, 0.75, 1.0} {
+				if got, want := tt.left.Vector(s), tt.curve.Vector(s*split); !approxVector(got, want) {
+					t.Errorf("%s split %f: left(%f) got %v want %v", tt.name, split, s, got, want)
+				}
+				if got, want := tt.right.Vector(s), tt.curve.Vector(split+s*(1-split)); !approxVector(got, want) {
+					t.Errorf("%s split %f: right(%f) got %v want %v", tt.name, split, s, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestBezierElevate(t *testing.T) {
+	quadratic := NewQuadraticBezierCurve(m.Vector{0, 0, 0}, m.Vector{2, 4, -2}, m.Vector{4, 0, 1})
+	cubic := quadratic.Elevate()
+	quartic := cubic.Elevate()
+	if len(quartic.controlPoints) != 5 {
+		t.Fatalf("quartic has %d control points, want 5", len(quartic.controlPoints))
+	}
+	if empty := NewBezierCurve(nil).Elevate(); len(empty.controlPoints) != 0 {
+		t.Errorf("empty curve elevated to %d control points", len(empty.controlPoints))
+	}
+	for _, s := range []float64{0.0, 0.25, 0.5, 0.75, 1.0} {
+		want := quadratic.Vector(s)
+		if got := cubic.Vector(s); !approxVector(got, want) {
+			t.Errorf("cubic(%f): got %v want %v", s, got, want)
+		}
+		if got := quartic.Vector(s); !approxVector(got, want) {
+			t.Errorf("quartic(%f): got %v want %v", s, got, want)
+		}
+	}
+}
+
+func TestBezierPatch(t *testing.T) {
+	points := flatPoints(0)
+	for i, p := range points {
+		points[i].Z = p.X*p.Y - p.Y*p.Y/2
+	}
+	bicubic, err := NewBicubicBezierPatch(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := NewBezierPatch(3, 3, points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []float64{0, 0.25, 0.5, 1} {
+		for _, v := range []float64{0, 0.5, 0.75, 1} {
+			if got, want := patch.Evaluate(u, v), bicubic.Evaluate(u, v); !approxVector(got, want) {
+				t.Errorf("(%f,%f): got %v want %v", u, v, got, want)
+			}
+			if got, want := patch.dU(u, v), dUBezier(points, u, v); !approxVector(got, want) {
+				t.Errorf("(%f,%f): dU got %v want %v", u, v, got, want)
+			}
+			if got, want := patch.dV(u, v), dVBezier(points, u, v); !approxVector(got, want) {
+				t.Errorf("(%f,%f): dV got %v want %v", u, v, got, want)
+			}
+		}
+	}
+}
+
+func TestBezierPatchMixedDegree(t *testing.T) {
+	// quadratic along u, linear along v: a parabolic cylinder
+	points := []m.Vector{
+		{0, 0, 0}, {1, 2, 0}, {2, 0, 0},
+		{0, 0, 1}, {1, 2, 1}, {2, 0, 1},
+	}
+	patch, err := NewBezierPatch(2, 1, points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := patch.Evaluate(0.5, 0.5), v(1, 1, 0.5); !approxVector(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := patch.normal(0.5, 0.5), v(0, -1, 0); !approxVector(got, want) {
+		t.Errorf("normal got %v want %v", got, want)
+	}
+}
+
+func TestBezierPatchInvalid(t *testing.T) {
+	if _, err := NewBicubicBezierPatch(make([]m.Vector, 15)); err == nil {
+		t.Error("bicubic patch with 15 points: expected error")
+	}
+	if _, err := NewBezierPatch(2, 3, make([]m.Vector, 16)); err == nil {
+		t.Error("2x3 patch with 16 points: expected error")
+	}
+	if _, err := NewBezierPatch(0, 3, make([]m.Vector, 4)); err == nil {
+		t.Error("0x3 patch: expected error")
+	}
+}
+
+func TestRationalBezierArc(t *testing.T) {
+	center := m.Vector{1, 2, 0}
+	xaxis, yaxis := m.Vector{1, 0, 0}, m.Vector{0, 1, 0}
+	for i, tt := range []struct {
+		min, max float64
+	}{
+		{min: 0, max: math.Pi / 2.0},
+		{min: 0.3, max: 2.5},
+		{min: -1, max: 1},
+		{min: 0, max: math.Pi},
+		{min: 1, max: 1 + 2*math.Pi},
+		{min: 2, max: -2},
+	} {
+		arc := NewCircle(center, 2).Arc(tt.min, tt.max, xaxis, yaxis)
+		// away from the joints between segments,
+		// where the second derivative jumps
+		for _, s := range []float64{0, 0.1, 0.3, 0.6, 0.9, 1} {
+			p := arc.Vector(s)
+			radial := m.VectorFromTo(center, p)
+			if r := radial.Length(); math.Abs(float64(r)-2) > 1e-4 {
+				t.Errorf("%d) t=%f: point %v at distance %f from center", i, s, p, r)
+			}
+			d := arc.Derivative().Vector(s)
+			if dot := d.Normalize().Dot(radial.Normalize()); math.Abs(float64(dot)) > 1e-4 {
+				t.Errorf("%d) t=%f: tangent %v not perpendicular to radius", i, s, d)
+			}
+			// finite difference check of the second derivative
+			h := 1e-3
+			fd := arc.Derivative().Vector(s + h).Sub(arc.Derivative().Vector(s - h)).Times(float32(1 / (2 * h)))
+			if got := arc.SecondDerivative().Vector(s); got.Sub(fd).Length() > 1e-2*fd.Length() {
+				t.Errorf("%d) t=%f: second derivative got %v want about %v", i, s, got, fd)
+			}
+		}
+		if got, want := arc.Vector(1), NewCircle(center, 2).Point(tt.max, xaxis, yaxis); !approxVector(got, want) {
+			t.Errorf("%d): end got %v want %v", i, got, want)
+		}
+	}
+}
+
+func TestRationalBezierUnitWeights(t *testing.T) {
+	p0, p1, p2, p3 := m.Vector{1, 2, 3}, m.Vector{4, -5, 6}, m.Vector{7, 8, -9}, m.Vector{10, 11, 12}
+	cubic := NewCubicBezierCurve(p0, p1, p2, p3)
+	rational, err := NewRationalCubicBezierCurve(p0, p1, p2, p3, 1, 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// scaling all weights does not change the curve
+	scaled, _ := NewRationalCubicBezierCurve(p0, p1, p2, p3, 3, 3, 3, 3)
+	for _, s := range []float64{0, 0.25, 0.5, 0.75, 1} {
+		want := cubic.Vector(s)
+		if got := rational.Vector(s); !approxVector(got, want) {
+			t.Errorf("t=%f: got %v want %v", s, got, want)
+		}
+		if got := scaled.Vector(s); !approxVector(got, want) {
+			t.Errorf("t=%f: scaled got %v want %v", s, got, want)
+		}
+		if got, want := rational.Derivative().Vector(s), cubic.Derivative().Vector(s); !approxVector(got, want) {
+			t.Errorf("t=%f: derivative got %v want %v", s, got, want)
+		}
+	}
+}
+
+func TestRationalBezierInvalidWeights(t *testing.T) {
+	p0, p1, p2 := m.Vector{0, 0, 0}, m.Vector{1, 1, 0}, m.Vector{2, 0, 0}
+	if _, err := NewRationalQuadraticBezierCurve(p0, p1, p2, 1, 0, 1); err == nil {
+		t.Error("expected error for zero weight")
+	}
+	if _, err := NewConicArc(p0, p1, p2, -0.5); err == nil {
+		t.Error("expected error for negative weight")
+	}
+	if _, err := NewRationalCubicBezierCurve(p0, p1, p2, p2, 1, 1, 1, -1); err == nil {
+		t.Error("expected error for negative weight")
+	}
+}
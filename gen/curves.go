@@ -37,6 +37,35 @@ func (e ellipse) PointsPhaseRange(minPhase, maxPhase float64, n int, xaxis, yaxi
 	return l
 }
 
+// Arc returns the part of the ellipse between phase min and max
+// as an exact quadratic nurbs curve. like NewNURBSEllipse it is made of
+// rational segments spanning at most a quarter turn each, since the
+// shoulder point of a single segment goes to infinity at half a turn
+func (e ellipse) Arc(minPhase, maxPhase float64, xaxis, yaxis m.Vector) nurbsCurve {
+	n := int(math.Ceil(math.Abs(maxPhase-minPhase) / (math.Pi / 2)))
+	if n < 1 {
+		n = 1
+	}
+	half := (maxPhase - minPhase) / float64(2*n)
+	w := math.Cos(half)
+	points := []m.Vector{e.Point(minPhase, xaxis, yaxis)}
+	weights := []float64{1}
+	knots := []float64{0, 0, 0}
+	for i := 0; i < n; i++ {
+		// shoulder point is where the tangents at both ends meet
+		mid := minPhase + float64(2*i+1)*half
+		x := xaxis.Times(e.radiusx * float32(math.Cos(mid)/w))
+		y := yaxis.Times(e.radiusy * float32(math.Sin(mid)/w))
+		points = append(points, e.center.Add(x).Add(y), e.Point(mid+half, xaxis, yaxis))
+		weights = append(weights, w, 1)
+		knot := float64(i+1) / float64(n)
+		knots = append(knots, knot, knot)
+	}
+	knots = append(knots, 1)
+	curve, _ := NewNURBSCurve(2, points, weights, knots)
+	return curve
+}
+
 type sphere struct {
 	center m.Vector
 	radius float32
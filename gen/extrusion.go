@@ -10,6 +10,11 @@ import (
 // the back face is the front face mirrored
 // the side is made by pairwise joining the points of front and back
 func ExtrudeSolidFace(points []m.Vector, extrusionVector m.Vector, mat m.Material) m.Object {
+	return m.NewTriangleComplexObject(ExtrudeSolidFaceTriangles(points, extrusionVector, mat))
+}
+
+// as ExtrudeSolidFace, but returning the triangles instead of an object
+func ExtrudeSolidFaceTriangles(points []m.Vector, extrusionVector m.Vector, mat m.Material) []m.Triangle {
 	triangles := []m.Triangle{}
 
 	// front/back faces
@@ -27,7 +32,7 @@ func ExtrudeSolidFace(points []m.Vector, extrusionVector m.Vector, mat m.Materia
 		ex[i] = p.Add(extrusionVector)
 	}
 	triangles = append(triangles, JoinPoints([][]m.Vector{points, ex}, mat)...)
-	return m.NewTriangleComplexObject(triangles)
+	return triangles
 }
 
 // dumb algorithm for front face: join triangles radiating from one point
@@ -57,6 +62,11 @@ type ExtrusionFace struct {
 }
 
 func (ef ExtrusionFace) Extrude(extrusionVector m.Vector) m.Object {
+	return m.NewTriangleComplexObject(ef.ExtrudeTriangles(extrusionVector))
+}
+
+// as Extrude, but returning the triangles instead of an object
+func (ef ExtrusionFace) ExtrudeTriangles(extrusionVector m.Vector) []m.Triangle {
 	triangles := []m.Triangle{}
 	for _, t := range ef.Front {
 		triangles = append(triangles, m.NewTriangle(t.P0, t.P1, t.P2, ef.Material))
@@ -78,7 +88,7 @@ func (ef ExtrusionFace) Extrude(extrusionVector m.Vector) m.Object {
 		}
 		triangles = append(triangles, JoinPoints([][]m.Vector{list, ex}, ef.Material)...)
 	}
-	return m.NewTriangleComplexObject(triangles)
+	return triangles
 }
 
 func (ef ExtrusionFace) ExtrudeNonCircular(extrusionVector m.Vector) m.Object {
@@ -129,12 +129,20 @@ func (l Lleaf) GetPoints() []m.Vector {
 
 type Lbranch struct {
 	points []m.Vector
+	// stack depth at which each point was drawn
+	depths []int
 }
 
 func (b Lbranch) GetPoints() []m.Vector {
 	return b.points
 }
 
+// GetDepths returns for each point the number of branches ('[')
+// the turtle was in when drawing the line to it
+func (b Lbranch) GetDepths() []int {
+	return b.depths
+}
+
 func draw(instrs []turtleInstruction, d float32, delta float64) []Lsegment {
 	// turtle starts in origin facing up
 	origin := m.Vector{0, 0, 0}
@@ -144,6 +152,7 @@ func draw(instrs []turtleInstruction, d float32, delta float64) []Lsegment {
 
 	segments := []Lsegment{}
 	seg := []m.Vector{t.pos}
+	depths := []int{0}
 	var leafSeg []m.Vector
 	var leafMaking bool
 	for _, instr := range instrs {
@@ -154,6 +163,7 @@ func draw(instrs []turtleInstruction, d float32, delta float64) []Lsegment {
 				leafSeg = append(leafSeg, t.pos)
 			} else {
 				seg = append(seg, t.pos)
+				depths = append(depths, len(stack))
 			}
 		case turnLeft:
 			t.heading, L, H = transformAxes(delta, U, t.heading, L, H)
@@ -178,9 +188,10 @@ func draw(instrs []turtleInstruction, d float32, delta float64) []Lsegment {
 			t = newPos.turtle
 			H, L, U = newPos.H, newPos.L, newPos.U
 			if len(seg) > 1 {
-				segments = append(segments, Lbranch{points: seg})
+				segments = append(segments, Lbranch{points: seg, depths: depths})
 			}
 			seg = []m.Vector{t.pos}
+			depths = []int{len(stack)}
 		case startLeaf:
 			leafMaking = true
 			leafSeg = []m.Vector{t.pos}
@@ -190,7 +201,7 @@ func draw(instrs []turtleInstruction, d float32, delta float64) []Lsegment {
 		}
 	}
 	if len(seg) > 1 {
-		segments = append(segments, Lbranch{points: seg})
+		segments = append(segments, Lbranch{points: seg, depths: depths})
 	}
 	return segments
 }
@@ -0,0 +1,170 @@
+package gen
+
+import (
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// Mesh is an indexed triangle mesh: faces index into the vertex list.
+// normals and uvs are optional, but if present hold one entry per vertex
+type Mesh struct {
+	Vertices []m.Vector
+	Normals  []m.Vector
+	UVs      []m.Vector
+	Faces    [][3]int
+}
+
+// MeshFromTriangles builds an indexed mesh from separate triangles,
+// reusing vertices that are in exactly the same position
+func MeshFromTriangles(triangles []m.Triangle) Mesh {
+	mesh := Mesh{}
+	indices := map[m.Vector]int{}
+	vertex := func(p m.Vector) int {
+		if i, ok := indices[p]; ok {
+			return i
+		}
+		i := len(mesh.Vertices)
+		indices[p] = i
+		mesh.Vertices = append(mesh.Vertices, p)
+		return i
+	}
+	for _, t := range triangles {
+		mesh.Faces = append(mesh.Faces, [3]int{vertex(t.P0), vertex(t.P1), vertex(t.P2)})
+	}
+	return mesh
+}
+
+// GridMesh samples a surface on the same grid as Triangulate,
+// including normals if the surface has them and uvs
+func GridMesh(s ParametricSurface, samples int) Mesh {
+	n := samples + 1
+	mesh := Mesh{
+		Vertices: make([]m.Vector, n*n),
+		UVs:      make([]m.Vector, n*n),
+	}
+	ns, hasNormals := s.(normalSurface)
+	if hasNormals {
+		mesh.Normals = make([]m.Vector, n*n)
+	}
+	f64s := float64(samples)
+	for intv := 0; intv <= samples; intv++ {
+		for intu := 0; intu <= samples; intu++ {
+			u, v := float64(intu)/f64s, float64(intv)/f64s
+			i := intv*n + intu
+			mesh.Vertices[i] = s.Evaluate(u, v)
+			mesh.UVs[i] = m.Vector{float32(u), float32(v), 0}
+			if hasNormals {
+				mesh.Normals[i] = ns.normal(u, v)
+			}
+		}
+	}
+	// same orientation as adaptive tessellation: counterclockwise in (u,v)
+	for intv := 0; intv < samples; intv++ {
+		for intu := 0; intu < samples; intu++ {
+			p00 := intv*n + intu
+			p10, p01, p11 := p00+1, p00+n, p00+n+1
+			mesh.Faces = append(mesh.Faces, [3]int{p00, p10, p11}, [3]int{p00, p11, p01})
+		}
+	}
+	return mesh
+}
+
+// Triangles returns the faces of the mesh as separate triangles
+func (mesh Mesh) Triangles(mat m.Material) []m.Triangle {
+	triangles := make([]m.Triangle, len(mesh.Faces))
+	for i, f := range mesh.Faces {
+		triangles[i] = m.NewTriangle(mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]], mat)
+	}
+	return triangles
+}
+
+// Object returns the mesh as a renderable object.
+// if the mesh has normals, these are interpolated across each triangle
+// NOTE: uvs are not passed on, textures relying on TriangleMeshUVFunc
+// only work on grid meshes
+func (mesh Mesh) Object(mat m.Material) m.Object {
+	if len(mesh.Normals) != len(mesh.Vertices) {
+		return m.NewTriangleComplexObject(mesh.Triangles(mat))
+	}
+	triangles := make([]m.Triangle, len(mesh.Faces))
+	for i, f := range mesh.Faces {
+		p0, p1, p2 := mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]]
+		n0, n1, n2 := mesh.Normals[f[0]], mesh.Normals[f[1]], mesh.Normals[f[2]]
+		triangles[i] = m.NewTriangle(p0, p1, p2, smoothShadingMaterial(mat, p0, p1, p2, n0, n1, n2))
+	}
+	return m.NewTriangleComplexObject(triangles)
+}
+
+// smoothShadingMaterial interpolates vertex normals over a single triangle
+// using barycentric coordinates of the untransformed hit point
+// NOTE: one material per triangle, see sphere.NormalMappedSphere
+func smoothShadingMaterial(mat m.Material, p0, p1, p2, n0, n1, n2 m.Vector) m.Material {
+	coords := barycentric(p0, p1, p2)
+	return &m.NormalMappingMaterial{
+		WrappedMaterial: mat,
+		NormalFunc: func(si *m.SurfaceInteraction) m.Vector {
+			b, ok := coords(si.UntransformedPoint)
+			if !ok {
+				return n0
+			}
+			return interpolate(b, n0, n1, n2).Normalize()
+		},
+	}
+}
+
+// barycentric returns a function giving the barycentric coordinates
+// of a point in the triangle p0 p1 p2, or false if the triangle is degenerate
+func barycentric(p0, p1, p2 m.Vector) func(p m.Vector) ([3]float32, bool) {
+	e1, e2 := m.VectorFromTo(p0, p1), m.VectorFromTo(p0, p2)
+	d11, d12, d22 := e1.Dot(e1), e1.Dot(e2), e2.Dot(e2)
+	denom := d11*d22 - d12*d12
+	return func(p m.Vector) ([3]float32, bool) {
+		if denom == 0 {
+			return [3]float32{}, false
+		}
+		e := m.VectorFromTo(p0, p)
+		d1, d2 := e.Dot(e1), e.Dot(e2)
+		b1 := (d22*d1 - d12*d2) / denom
+		b2 := (d11*d2 - d12*d1) / denom
+		return [3]float32{1 - b1 - b2, b1, b2}, true
+	}
+}
+
+// interpolate weighs three vectors by barycentric coordinates
+func interpolate(b [3]float32, v0, v1, v2 m.Vector) m.Vector {
+	return v0.Times(b[0]).Add(v1.Times(b[1])).Add(v2.Times(b[2]))
+}
+
+// Transform returns a copy of the mesh with the transformation applied
+// NOTE: normals are transformed as vectors, which is only correct
+// for rotations, translations and uniform scaling
+func (mesh Mesh) Transform(t m.Transform) Mesh {
+	transformed := Mesh{
+		Vertices: make([]m.Vector, len(mesh.Vertices)),
+		UVs:      mesh.UVs,
+		Faces:    mesh.Faces,
+	}
+	for i, v := range mesh.Vertices {
+		transformed.Vertices[i] = t.Point(v)
+	}
+	if mesh.Normals != nil {
+		transformed.Normals = make([]m.Vector, len(mesh.Normals))
+		for i, n := range mesh.Normals {
+			transformed.Normals[i] = t.Vector(n).Normalize()
+		}
+	}
+	return transformed
+}
+
+// Bounds returns the lowest and highest coordinates of the vertices
+// along each axis, or zero vectors for a mesh without vertices
+func (mesh Mesh) Bounds() (min, max m.Vector) {
+	if len(mesh.Vertices) == 0 {
+		return m.Vector{}, m.Vector{}
+	}
+	min, max = mesh.Vertices[0], mesh.Vertices[0]
+	for _, v := range mesh.Vertices {
+		min = m.Vector{minf(min.X, v.X), minf(min.Y, v.Y), minf(min.Z, v.Z)}
+		max = m.Vector{maxf(max.X, v.X), maxf(max.Y, v.Y), maxf(max.Z, v.Z)}
+	}
+	return min, max
+}
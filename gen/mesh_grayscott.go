@@ -0,0 +1,315 @@
+package gen
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// MeshGrayScottInput configures Gray-Scott reaction-diffusion on mesh vertices.
+// rates mean the same as in GrayScottInput, with the vertex most tightly
+// connected to its neighbours playing the role of a pixel
+type MeshGrayScottInput struct {
+	Iterations int
+	FeedRate   float64
+	KillRate   float64
+	DiffRateA  float64
+	DiffRateB  float64
+	// number of goroutines sharing the work, defaults to the number of cpus
+	Workers int
+	// random source for the initial state, as in GrayScottInput
+	Seed int64
+	Rand *rand.Rand
+	// initial concentration of B at a vertex, called for each vertex in order.
+	// defaults to B in a random 1/50 of the vertices
+	Initial func(p m.Vector, rnd *rand.Rand) float64
+}
+
+// cotangent laplacian in compressed rows: the neighbours of vertex i are
+// neighbours[offsets[i]:offsets[i+1]] with the same weights
+type meshLaplacian struct {
+	offsets    []int
+	neighbours []int
+	weights    []float64
+}
+
+// GrayScottMesh runs reaction-diffusion on the vertices of a mesh and returns
+// the concentrations of A and B per vertex. diffusion uses the cotangent
+// laplacian, so patterns follow the surface instead of a uv parametrisation;
+// weld separate patches first with WeldMeshes to let patterns cross borders
+func GrayScottMesh(mesh Mesh, input MeshGrayScottInput) ([]float64, []float64) {
+	n := len(mesh.Vertices)
+	rnd := input.Rand
+	if rnd == nil {
+		rnd = rand.New(rand.NewSource(input.Seed))
+	}
+	initial := input.Initial
+	if initial == nil {
+		initial = func(p m.Vector, rnd *rand.Rand) float64 {
+			if rnd.Float64() < seedRate {
+				return 1.0
+			}
+			return 0.0
+		}
+	}
+	a, b := make([]float64, n), make([]float64, n)
+	for i, p := range mesh.Vertices {
+		a[i] = 1.0
+		b[i] = initial(p, rnd)
+	}
+
+	workers := input.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > n {
+		workers = n
+	}
+	lap := cotangentLaplacian(mesh)
+	nextA, nextB := make([]float64, n), make([]float64, n)
+	f, k, da, db := input.FeedRate, input.KillRate, input.DiffRateA, input.DiffRateB
+	var wg sync.WaitGroup
+	for iter := 0; iter < input.Iterations; iter++ {
+		wg.Add(workers)
+		for w := 0; w < workers; w++ {
+			i0, i1 := w*n/workers, (w+1)*n/workers
+			go func() {
+				for i := i0; i < i1; i++ {
+					ai, bi := a[i], b[i]
+					var la, lb float64
+					for j := lap.offsets[i]; j < lap.offsets[i+1]; j++ {
+						nb, wt := lap.neighbours[j], lap.weights[j]
+						la += wt * (a[nb] - ai)
+						lb += wt * (b[nb] - bi)
+					}
+					nextA[i] = ai + ((da * la) - (ai * bi * bi) + (f * (1.0 - ai)))
+					nextB[i] = bi + ((db * lb) + (ai * bi * bi) - ((k + f) * bi))
+				}
+				wg.Done()
+			}()
+		}
+		wg.Wait()
+		a, nextA = nextA, a
+		b, nextB = nextB, b
+	}
+	return a, b
+}
+
+// cotangentLaplacian weighs each edge by half the sum of the cotangents of
+// the angles opposite to it, divided by a third of the area around the vertex.
+// negative weights of obtuse triangles are dropped to keep the explicit
+// integration stable. weights are scaled so that the vertex weighing its
+// neighbours most does so in total as much as a pixel does in GrayScott;
+// anything more and the explicit update overshoots
+func cotangentLaplacian(mesh Mesh) meshLaplacian {
+	n := len(mesh.Vertices)
+	weights := make([]map[int]float64, n)
+	for i := range weights {
+		weights[i] = map[int]float64{}
+	}
+	area := make([]float64, n)
+	for _, f := range mesh.Faces {
+		p := [3]m.Vector{mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]]}
+		doubleArea := float64(m.VectorFromTo(p[0], p[1]).Cross(m.VectorFromTo(p[0], p[2])).Length())
+		if doubleArea == 0 {
+			continue
+		}
+		for c := 0; c < 3; c++ {
+			i, j := f[(c+1)%3], f[(c+2)%3]
+			// cotangent of the angle at corner c
+			e1 := m.VectorFromTo(p[c], p[(c+1)%3])
+			e2 := m.VectorFromTo(p[c], p[(c+2)%3])
+			cot := float64(e1.Dot(e2)) / doubleArea
+			weights[i][j] += cot / 2
+			weights[j][i] += cot / 2
+			area[f[c]] += doubleArea / 6
+		}
+	}
+	lap := meshLaplacian{offsets: make([]int, n+1)}
+	for i, ws := range weights {
+		lap.offsets[i] = len(lap.neighbours)
+		if area[i] == 0 {
+			continue
+		}
+		for j := range ws {
+			lap.neighbours = append(lap.neighbours, j)
+		}
+		// fixed order, so results do not depend on map iteration
+		sort.Ints(lap.neighbours[lap.offsets[i]:])
+		for _, j := range lap.neighbours[lap.offsets[i]:] {
+			lap.weights = append(lap.weights, math.Max(ws[j], 0)/area[i])
+		}
+	}
+	lap.offsets[n] = len(lap.neighbours)
+	max := 0.0
+	for i := 0; i < n; i++ {
+		total := 0.0
+		for _, w := range lap.weights[lap.offsets[i]:lap.offsets[i+1]] {
+			total += w
+		}
+		max = math.Max(max, total)
+	}
+	if max > 0 {
+		for i := range lap.weights {
+			lap.weights[i] /= max
+		}
+	}
+	return lap
+}
+
+// GrayScottColors maps concentrations of B per vertex to grey
+// the same way GrayScott does for pixels
+func GrayScottColors(b []float64) []color.Color {
+	colors := make([]color.Color, len(b))
+	for i, v := range b {
+		bw := uint8((1 - v) * 255)
+		colors[i] = color.RGBA{bw, bw, bw, 255}
+	}
+	return colors
+}
+
+// ColoredObject returns the mesh as a renderable object with a diffuse
+// material per triangle, coloured by the average of its vertex colours
+func (mesh Mesh) ColoredObject(colors []color.Color) m.Object {
+	triangles := make([]m.Triangle, len(mesh.Faces))
+	for i, f := range mesh.Faces {
+		var r, g, b uint32
+		for _, v := range f {
+			cr, cg, cb, _ := colors[v].RGBA()
+			r, g, b = r+cr, g+cg, b+cb
+		}
+		c := m.NewColor(uint8(r/3>>8), uint8(g/3>>8), uint8(b/3>>8))
+		mat := m.NewDiffuseMaterial(m.ConstantTexture{Color: c})
+		triangles[i] = m.NewTriangle(mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]], mat)
+	}
+	return m.NewTriangleComplexObject(triangles)
+}
+
+// BakeTexture paints vertex colours into a w by h image using the uvs
+// of the mesh, interpolating over each triangle. u runs along the x axis
+// and v along the y axis of the image; pixels outside all triangles
+// stay transparent
+func (mesh Mesh) BakeTexture(colors []color.Color, w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	if len(mesh.UVs) != len(mesh.Vertices) {
+		return img
+	}
+	for _, f := range mesh.Faces {
+		var uv [3][2]float64
+		var rgba [3][4]float64
+		for c, v := range f {
+			uv[c] = [2]float64{float64(mesh.UVs[v].X) * float64(w), float64(mesh.UVs[v].Y) * float64(h)}
+			r, g, b, a := colors[v].RGBA()
+			rgba[c] = [4]float64{float64(r), float64(g), float64(b), float64(a)}
+		}
+		bakeTriangle(img, uv, rgba)
+	}
+	return img
+}
+
+// bakeTriangle fills the pixels whose centres lie in the triangle,
+// or on its edges so neighbouring triangles leave no gaps
+func bakeTriangle(img *image.RGBA, uv [3][2]float64, rgba [3][4]float64) {
+	minX := math.Floor(math.Min(uv[0][0], math.Min(uv[1][0], uv[2][0])))
+	maxX := math.Ceil(math.Max(uv[0][0], math.Max(uv[1][0], uv[2][0])))
+	minY := math.Floor(math.Min(uv[0][1], math.Min(uv[1][1], uv[2][1])))
+	maxY := math.Ceil(math.Max(uv[0][1], math.Max(uv[1][1], uv[2][1])))
+	denom := (uv[1][1]-uv[2][1])*(uv[0][0]-uv[2][0]) + (uv[2][0]-uv[1][0])*(uv[0][1]-uv[2][1])
+	if denom == 0 {
+		return
+	}
+	bounds := img.Bounds()
+	const eps = 1e-9
+	for y := int(minY); y <= int(maxY); y++ {
+		for x := int(minX); x <= int(maxX); x++ {
+			if !(image.Point{x, y}.In(bounds)) {
+				continue
+			}
+			px, py := float64(x)+0.5, float64(y)+0.5
+			b0 := ((uv[1][1]-uv[2][1])*(px-uv[2][0]) + (uv[2][0]-uv[1][0])*(py-uv[2][1])) / denom
+			b1 := ((uv[2][1]-uv[0][1])*(px-uv[2][0]) + (uv[0][0]-uv[2][0])*(py-uv[2][1])) / denom
+			b2 := 1 - b0 - b1
+			if b0 < -eps || b1 < -eps || b2 < -eps {
+				continue
+			}
+			var c [4]uint8
+			for i := range c {
+				c[i] = uint8((b0*rgba[0][i] + b1*rgba[1][i] + b2*rgba[2][i]) / 0x101)
+			}
+			img.SetRGBA(x, y, color.RGBA{c[0], c[1], c[2], c[3]})
+		}
+	}
+}
+
+// WeldMeshes joins meshes into one, merging vertices closer together than
+// a millionth of the size of the whole, such as the shared borders of
+// patches triangulated separately. it also returns for each mesh the index
+// of each of its vertices in the welded mesh. normals and uvs are dropped
+func WeldMeshes(meshes []Mesh) (Mesh, [][]int) {
+	var min, max m.Vector
+	first := true
+	for _, mesh := range meshes {
+		for _, v := range mesh.Vertices {
+			if first {
+				min, max, first = v, v, false
+			}
+			min = m.Vector{minf(min.X, v.X), minf(min.Y, v.Y), minf(min.Z, v.Z)}
+			max = m.Vector{maxf(max.X, v.X), maxf(max.Y, v.Y), maxf(max.Z, v.Z)}
+		}
+	}
+	epsilon := 1e-6 * float64(m.VectorFromTo(min, max).Length())
+	if epsilon == 0 {
+		epsilon = 1e-6
+	}
+	cell := func(v m.Vector) [3]int64 {
+		return [3]int64{
+			int64(math.Floor(float64(v.X) / epsilon)),
+			int64(math.Floor(float64(v.Y) / epsilon)),
+			int64(math.Floor(float64(v.Z) / epsilon)),
+		}
+	}
+	// vertices by grid cell of size epsilon; a close vertex is
+	// in the same cell or one of its neighbours
+	grid := map[[3]int64][]int{}
+	welded := Mesh{}
+	find := func(v m.Vector) int {
+		c := cell(v)
+		for dx := int64(-1); dx <= 1; dx++ {
+			for dy := int64(-1); dy <= 1; dy++ {
+				for dz := int64(-1); dz <= 1; dz++ {
+					for _, i := range grid[[3]int64{c[0] + dx, c[1] + dy, c[2] + dz}] {
+						if float64(m.VectorFromTo(welded.Vertices[i], v).Length()) <= epsilon {
+							return i
+						}
+					}
+				}
+			}
+		}
+		i := len(welded.Vertices)
+		welded.Vertices = append(welded.Vertices, v)
+		grid[c] = append(grid[c], i)
+		return i
+	}
+
+	indices := make([][]int, len(meshes))
+	for n, mesh := range meshes {
+		indices[n] = make([]int, len(mesh.Vertices))
+		for i, v := range mesh.Vertices {
+			indices[n][i] = find(v)
+		}
+		for _, f := range mesh.Faces {
+			face := [3]int{indices[n][f[0]], indices[n][f[1]], indices[n][f[2]]}
+			if face[0] == face[1] || face[1] == face[2] || face[2] == face[0] {
+				continue
+			}
+			welded.Faces = append(welded.Faces, face)
+		}
+	}
+	return welded, indices
+}
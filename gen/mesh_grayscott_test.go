@@ -0,0 +1,104 @@
+package gen
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestCotangentLaplacianLinear(t *testing.T) {
+	mesh := flatGrid(t, 0, 8)
+	lap := cotangentLaplacian(mesh)
+	// the laplacian of a linear function is zero away from the border
+	f := func(v m.Vector) float64 { return float64(v.X) + 2*float64(v.Y) }
+	for i, v := range mesh.Vertices {
+		if v.X == 0 || v.X == 3 || v.Y == 0 || v.Y == 3 {
+			continue
+		}
+		var sum, total float64
+		for j := lap.offsets[i]; j < lap.offsets[i+1]; j++ {
+			sum += lap.weights[j] * (f(mesh.Vertices[lap.neighbours[j]]) - f(v))
+			total += lap.weights[j]
+		}
+		if math.Abs(sum) > 1e-6 {
+			t.Errorf("vertex %d: got laplacian %f want 0", i, sum)
+		}
+		if total <= 0 {
+			t.Errorf("vertex %d: no weight on neighbours", i)
+		}
+	}
+}
+
+func TestWeldMeshes(t *testing.T) {
+	a, b := flatGrid(t, 0, 8), flatGrid(t, 3, 8)
+	welded, indices := WeldMeshes([]Mesh{a, b})
+	if got, want := len(welded.Vertices), 2*81-9; got != want {
+		t.Fatalf("got %d vertices want %d", got, want)
+	}
+	if got, want := len(welded.Faces), len(a.Faces)+len(b.Faces); got != want {
+		t.Fatalf("got %d faces want %d", got, want)
+	}
+	// right border of a is the left border of b
+	for row := 0; row <= 8; row++ {
+		if indices[0][row*9+8] != indices[1][row*9] {
+			t.Errorf("row %d: border vertices not welded", row)
+		}
+	}
+}
+
+func TestGrayScottMesh(t *testing.T) {
+	mesh, _ := WeldMeshes([]Mesh{flatGrid(t, 0, 16), flatGrid(t, 3, 16)})
+	input := MeshGrayScottInput{
+		Iterations: 100,
+		FeedRate:   0.055,
+		KillRate:   0.062,
+		DiffRateA:  1.0,
+		DiffRateB:  0.5,
+		Seed:       7,
+	}
+	a1, b1 := GrayScottMesh(mesh, input)
+	input.Workers = 3
+	a2, b2 := GrayScottMesh(mesh, input)
+	for i := range a1 {
+		if a1[i] != a2[i] || b1[i] != b2[i] {
+			t.Fatalf("vertex %d: results differ between runs", i)
+		}
+		if math.IsNaN(a1[i]) || a1[i] < 0 || a1[i] > 1 || b1[i] < 0 || b1[i] > 1 {
+			t.Fatalf("vertex %d: concentrations out of range: %f %f", i, a1[i], b1[i])
+		}
+	}
+
+	// without B nothing happens
+	input.Initial = func(m.Vector, *rand.Rand) float64 { return 0 }
+	a, b := GrayScottMesh(mesh, input)
+	for i := range a {
+		if a[i] != 1 || b[i] != 0 {
+			t.Fatalf("vertex %d: got %f %f want 1 0", i, a[i], b[i])
+		}
+	}
+}
+
+func TestBakeTexture(t *testing.T) {
+	mesh := flatGrid(t, 0, 4)
+	colors := make([]color.Color, len(mesh.Vertices))
+	for i, uv := range mesh.UVs {
+		colors[i] = color.RGBA{uint8(uv.X * 200), 0, 0, 255}
+	}
+	img := mesh.BakeTexture(colors, 32, 16)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			r, _, _, a := img.At(x, y).RGBA()
+			if a != 0xffff {
+				t.Fatalf("pixel %d,%d not covered", x, y)
+			}
+			// red follows u, interpolated at the pixel centre
+			want := 200 * (float64(x) + 0.5) / 32
+			if got := float64(r >> 8); math.Abs(got-want) > 1.5 {
+				t.Fatalf("pixel %d,%d: got red %f want %f", x, y, got, want)
+			}
+		}
+	}
+}
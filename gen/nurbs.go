@@ -0,0 +1,312 @@
+package gen
+
+import (
+	"fmt"
+	"math"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// non-uniform rational b-spline curve, t in [0-1]
+// t is mapped onto the valid range of the knot vector
+// working from Piegl & Tiller, The NURBS Book
+type nurbsCurve struct {
+	parametricFunction
+	degree        int
+	controlPoints []m.Vector
+	weights       []float64
+	knots         []float64
+}
+
+// NewNURBSCurve expects len(knots) == len(points) + degree + 1
+// and one positive weight per control point
+func NewNURBSCurve(degree int, points []m.Vector, weights, knots []float64) (nurbsCurve, error) {
+	if err := validateNURBS(degree, len(points), weights, knots); err != nil {
+		return nurbsCurve{}, err
+	}
+	c := nurbsCurve{
+		degree:        degree,
+		controlPoints: points,
+		weights:       weights,
+		knots:         knots,
+	}
+	c.parametricFunction = NewParametricFunction(func(t float64) m.Vector {
+		return c.derivatives(t, 0)[0]
+	})
+	return c, nil
+}
+
+// a b-spline is a nurbs curve with all weights equal
+func NewBSplineCurve(degree int, points []m.Vector, knots []float64) (nurbsCurve, error) {
+	weights := make([]float64, len(points))
+	for i := range weights {
+		weights[i] = 1.0
+	}
+	return NewNURBSCurve(degree, points, weights, knots)
+}
+
+// NewUniformBSplineCurve uses a clamped uniform knot vector,
+// so the curve starts and ends in the first and last control point
+func NewUniformBSplineCurve(degree int, points []m.Vector) (nurbsCurve, error) {
+	if err := validateDegree(degree, len(points)); err != nil {
+		return nurbsCurve{}, err
+	}
+	return NewBSplineCurve(degree, points, clampedUniformKnots(len(points), degree))
+}
+
+// NewNURBSEllipse returns an exact ellipse around c with radii a and b
+// along xaxis and yaxis, as a quadratic nurbs curve with 9 control points
+func NewNURBSEllipse(c m.Vector, a, b float32, xaxis, yaxis m.Vector) nurbsCurve {
+	x, y := xaxis.Times(a), yaxis.Times(b)
+	points := []m.Vector{
+		c.Add(x),
+		c.Add(x).Add(y),
+		c.Add(y),
+		c.Sub(x).Add(y),
+		c.Sub(x),
+		c.Sub(x).Sub(y),
+		c.Sub(y),
+		c.Add(x).Sub(y),
+		c.Add(x),
+	}
+	w := math.Sqrt(2) / 2.0
+	weights := []float64{1, w, 1, w, 1, w, 1, w, 1}
+	knots := []float64{0, 0, 0, 0.25, 0.25, 0.5, 0.5, 0.75, 0.75, 1, 1, 1}
+	curve, _ := NewNURBSCurve(2, points, weights, knots)
+	return curve
+}
+
+func NewNURBSCircle(c m.Vector, r float32, xaxis, yaxis m.Vector) nurbsCurve {
+	return NewNURBSEllipse(c, r, r, xaxis, yaxis)
+}
+
+func validateNURBS(degree, numPoints int, weights, knots []float64) error {
+	if err := validateWeights(numPoints, weights); err != nil {
+		return err
+	}
+	return validateKnots(degree, numPoints, knots)
+}
+
+func validateWeights(numPoints int, weights []float64) error {
+	if len(weights) != numPoints {
+		return fmt.Errorf("Invalid number of weights: got %d want %d", len(weights), numPoints)
+	}
+	for i, w := range weights {
+		if w <= 0 {
+			return fmt.Errorf("Invalid weight %d: %f", i, w)
+		}
+	}
+	return nil
+}
+
+func validateDegree(degree, numPoints int) error {
+	if degree < 1 {
+		return fmt.Errorf("Invalid degree: %d", degree)
+	}
+	if numPoints < degree+1 {
+		return fmt.Errorf("Need at least %d control points for degree %d, got %d", degree+1, degree, numPoints)
+	}
+	return nil
+}
+
+func validateKnots(degree, numPoints int, knots []float64) error {
+	if err := validateDegree(degree, numPoints); err != nil {
+		return err
+	}
+	if len(knots) != numPoints+degree+1 {
+		return fmt.Errorf("Invalid knot vector length: got %d want %d", len(knots), numPoints+degree+1)
+	}
+	for i := 1; i < len(knots); i++ {
+		if knots[i] < knots[i-1] {
+			return fmt.Errorf("Knot vector not nondecreasing at %d: %v", i, knots)
+		}
+	}
+	if knots[degree] == knots[numPoints] {
+		return fmt.Errorf("Knot vector has empty domain: %v", knots)
+	}
+	return nil
+}
+
+// degree+1 zeroes and ones on either end, evenly spaced interior knots
+func clampedUniformKnots(numPoints, degree int) []float64 {
+	knots := make([]float64, numPoints+degree+1)
+	interior := numPoints - degree
+	for i := range knots {
+		switch {
+		case i <= degree:
+			knots[i] = 0
+		case i >= numPoints:
+			knots[i] = 1
+		default:
+			knots[i] = float64(i-degree) / float64(interior)
+		}
+	}
+	return knots
+}
+
+// derivatives returns the point on the curve and its first n derivatives wrt t
+func (c nurbsCurve) derivatives(t float64, n int) []m.Vector {
+	lo, hi := c.knots[c.degree], c.knots[len(c.controlPoints)]
+	u := lo + t*(hi-lo)
+	span := findSpan(len(c.controlPoints)-1, c.degree, u, c.knots)
+	ders := basisFunctionDerivatives(span, u, c.degree, n, c.knots)
+
+	aders := make([]m.Vector, n+1)
+	wders := make([]float64, n+1)
+	for k := 0; k <= n; k++ {
+		for j := 0; j <= c.degree; j++ {
+			i := span - c.degree + j
+			nw := ders[k][j] * c.weights[i]
+			aders[k] = aders[k].Add(c.controlPoints[i].Times(float32(nw)))
+			wders[k] += nw
+		}
+	}
+
+	curve := rationalDerivatives(aders, wders)
+	scale := 1.0
+	// chain rule for mapping t onto the knot range
+	for k := 1; k <= n; k++ {
+		scale *= hi - lo
+		curve[k] = curve[k].Times(float32(scale))
+	}
+	return curve
+}
+
+func (c nurbsCurve) Derivative() ParametricFunction {
+	return NewParametricFunction(func(t float64) m.Vector {
+		return c.derivatives(t, 1)[1]
+	})
+}
+
+func (c nurbsCurve) SecondDerivative() ParametricFunction {
+	return NewParametricFunction(func(t float64) m.Vector {
+		return c.derivatives(t, 2)[2]
+	})
+}
+
+// rationalDerivatives applies the quotient rule to a homogeneous curve A(t)/w(t)
+// given the derivatives of A and w up to the same order
+func rationalDerivatives(aders []m.Vector, wders []float64) []m.Vector {
+	curve := make([]m.Vector, len(aders))
+	for k := range aders {
+		v := aders[k]
+		for i := 1; i <= k; i++ {
+			v = v.Sub(curve[k-i].Times(float32(binomial(k, i) * wders[i])))
+		}
+		curve[k] = v.Times(float32(1 / wders[0]))
+	}
+	return curve
+}
+
+func binomial(n, k int) float64 {
+	b := 1.0
+	for i := 1; i <= k; i++ {
+		b = b * float64(n-k+i) / float64(i)
+	}
+	return b
+}
+
+// findSpan returns the index i of the knot span such that
+// knots[i] <= u < knots[i+1], where n is the last control point index
+func findSpan(n, degree int, u float64, knots []float64) int {
+	if u >= knots[n+1] {
+		// last span with nonzero length
+		for i := n; i > degree; i-- {
+			if knots[i] < knots[i+1] {
+				return i
+			}
+		}
+		return degree
+	}
+	if u <= knots[degree] {
+		return degree
+	}
+	low, high := degree, n+1
+	mid := (low + high) / 2
+	for u < knots[mid] || u >= knots[mid+1] {
+		if u < knots[mid] {
+			high = mid
+		} else {
+			low = mid
+		}
+		mid = (low + high) / 2
+	}
+	return mid
+}
+
+// basisFunctionDerivatives returns ders[k][j], the kth derivative
+// of the nonzero basis functions N(span-degree+j) at u, for k <= n
+func basisFunctionDerivatives(span int, u float64, degree, n int, knots []float64) [][]float64 {
+	p := degree
+	ndu := make([][]float64, p+1)
+	for i := range ndu {
+		ndu[i] = make([]float64, p+1)
+	}
+	left, right := make([]float64, p+1), make([]float64, p+1)
+	ndu[0][0] = 1.0
+	for j := 1; j <= p; j++ {
+		left[j] = u - knots[span+1-j]
+		right[j] = knots[span+j] - u
+		saved := 0.0
+		for r := 0; r < j; r++ {
+			// lower triangle stores knot differences
+			ndu[j][r] = right[r+1] + left[j-r]
+			temp := ndu[r][j-1] / ndu[j][r]
+			// upper triangle stores basis functions
+			ndu[r][j] = saved + right[r+1]*temp
+			saved = left[j-r] * temp
+		}
+		ndu[j][j] = saved
+	}
+
+	ders := make([][]float64, n+1)
+	for k := range ders {
+		ders[k] = make([]float64, p+1)
+	}
+	for j := 0; j <= p; j++ {
+		ders[0][j] = ndu[j][p]
+	}
+	// derivatives above the degree are zero
+	dn := n
+	if dn > p {
+		dn = p
+	}
+	a := [2][]float64{make([]float64, p+1), make([]float64, p+1)}
+	for r := 0; r <= p; r++ {
+		s1, s2 := 0, 1
+		a[0][0] = 1.0
+		for k := 1; k <= dn; k++ {
+			d := 0.0
+			rk, pk := r-k, p-k
+			if r >= k {
+				a[s2][0] = a[s1][0] / ndu[pk+1][rk]
+				d = a[s2][0] * ndu[rk][pk]
+			}
+			j1, j2 := 1, k-1
+			if rk < -1 {
+				j1 = -rk
+			}
+			if r-1 > pk {
+				j2 = p - r
+			}
+			for j := j1; j <= j2; j++ {
+				a[s2][j] = (a[s1][j] - a[s1][j-1]) / ndu[pk+1][rk+j]
+				d += a[s2][j] * ndu[rk+j][pk]
+			}
+			if r <= pk {
+				a[s2][k] = -a[s1][k-1] / ndu[pk+1][r]
+				d += a[s2][k] * ndu[r][pk]
+			}
+			ders[k][r] = d
+			s1, s2 = s2, s1
+		}
+	}
+	factor := float64(p)
+	for k := 1; k <= dn; k++ {
+		for j := 0; j <= p; j++ {
+			ders[k][j] *= factor
+		}
+		factor *= float64(p - k)
+	}
+	return ders
+}
@@ -0,0 +1,146 @@
+package gen
+
+import (
+	"fmt"
+	"math"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// tensor product nurbs surface, u and v in [0-1]
+// control points are stored row by row: numU points along u for each of numV rows,
+// so point (i, j) along (u, v) is controlPoints[j*numU + i]
+// same layout as bicubicBezierPatch
+type nurbsSurface struct {
+	degreeU, degreeV int
+	numU, numV       int
+	controlPoints    []m.Vector
+	weights          []float64
+	knotsU, knotsV   []float64
+}
+
+func NewNURBSSurface(degreeU, degreeV, numU, numV int, points []m.Vector, weights, knotsU, knotsV []float64) (nurbsSurface, error) {
+	if len(points) != numU*numV {
+		return nurbsSurface{}, fmt.Errorf("Invalid number of control points: got %d want %dx%d", len(points), numU, numV)
+	}
+	if err := validateWeights(len(points), weights); err != nil {
+		return nurbsSurface{}, err
+	}
+	if err := validateKnots(degreeU, numU, knotsU); err != nil {
+		return nurbsSurface{}, fmt.Errorf("u: %v", err)
+	}
+	if err := validateKnots(degreeV, numV, knotsV); err != nil {
+		return nurbsSurface{}, fmt.Errorf("v: %v", err)
+	}
+	return nurbsSurface{
+		degreeU:       degreeU,
+		degreeV:       degreeV,
+		numU:          numU,
+		numV:          numV,
+		controlPoints: points,
+		weights:       weights,
+		knotsU:        knotsU,
+		knotsV:        knotsV,
+	}, nil
+}
+
+// NewNURBSSphere returns an exact sphere: a semicircle from the bottom
+// to the top of the sphere along v, revolved around the y axis along u
+func NewNURBSSphere(c m.Vector, r float32) nurbsSurface {
+	w := math.Sqrt(2) / 2.0
+	radii := []float32{0, r, r, r, 0}
+	heights := []float32{-r, -r, 0, r, r}
+	weights := []float64{1, w, 1, w, 1}
+	knots := []float64{0, 0, 0, 0.5, 0.5, 1, 1, 1}
+	return revolve(c, radii, heights, weights, knots)
+}
+
+// NewNURBSTorus returns an exact torus around the y axis, with a tube
+// of radius r whose center is at distance R from c
+func NewNURBSTorus(c m.Vector, R, r float32) nurbsSurface {
+	w := math.Sqrt(2) / 2.0
+	radii := []float32{R + r, R + r, R, R - r, R - r, R - r, R, R + r, R + r}
+	heights := []float32{0, r, r, r, 0, -r, -r, -r, 0}
+	weights := []float64{1, w, 1, w, 1, w, 1, w, 1}
+	knots := []float64{0, 0, 0, 0.25, 0.25, 0.5, 0.5, 0.75, 0.75, 1, 1, 1}
+	return revolve(c, radii, heights, weights, knots)
+}
+
+// revolve a quadratic profile curve given as (radius, height) control points
+// around the y axis through c, using the 9 point nurbs circle along u.
+// the circle runs from x to -z so that with the profile going counterclockwise
+// (upwards on the outside) dU x dV points out of the surface
+func revolve(c m.Vector, radii, heights []float32, profileWeights, profileKnots []float64) nurbsSurface {
+	circle := NewNURBSCircle(m.Vector{}, 1, m.Vector{1, 0, 0}, m.Vector{0, 0, -1})
+	numU, numV := len(circle.controlPoints), len(radii)
+	points := make([]m.Vector, numU*numV)
+	weights := make([]float64, numU*numV)
+	for j := 0; j < numV; j++ {
+		for i, p := range circle.controlPoints {
+			points[j*numU+i] = c.Add(p.Times(radii[j])).Add(m.Vector{0, heights[j], 0})
+			weights[j*numU+i] = circle.weights[i] * profileWeights[j]
+		}
+	}
+	s, _ := NewNURBSSurface(2, 2, numU, numV, points, weights, circle.knots, profileKnots)
+	return s
+}
+
+func (s nurbsSurface) Evaluate(u, v float64) m.Vector {
+	p, _, _ := s.derivatives(u, v)
+	return p
+}
+
+// derivatives returns the point on the surface and
+// its partial derivatives wrt u and v
+func (s nurbsSurface) derivatives(u, v float64) (m.Vector, m.Vector, m.Vector) {
+	loU, hiU := s.knotsU[s.degreeU], s.knotsU[s.numU]
+	loV, hiV := s.knotsV[s.degreeV], s.knotsV[s.numV]
+	ku, kv := loU+u*(hiU-loU), loV+v*(hiV-loV)
+	spanU := findSpan(s.numU-1, s.degreeU, ku, s.knotsU)
+	spanV := findSpan(s.numV-1, s.degreeV, kv, s.knotsV)
+	dersU := basisFunctionDerivatives(spanU, ku, s.degreeU, 1, s.knotsU)
+	dersV := basisFunctionDerivatives(spanV, kv, s.degreeV, 1, s.knotsV)
+
+	// homogeneous point A and weight w, with partial derivatives
+	var a, au, av m.Vector
+	var w, wu, wv float64
+	for l := 0; l <= s.degreeV; l++ {
+		for k := 0; k <= s.degreeU; k++ {
+			index := (spanV-s.degreeV+l)*s.numU + spanU - s.degreeU + k
+			pw := s.weights[index]
+			p := s.controlPoints[index]
+			n := dersU[0][k] * dersV[0][l] * pw
+			nu := dersU[1][k] * dersV[0][l] * pw
+			nv := dersU[0][k] * dersV[1][l] * pw
+			a = a.Add(p.Times(float32(n)))
+			au = au.Add(p.Times(float32(nu)))
+			av = av.Add(p.Times(float32(nv)))
+			w, wu, wv = w+n, wu+nu, wv+nv
+		}
+	}
+	point := a.Times(float32(1 / w))
+	dU := au.Sub(point.Times(float32(wu))).Times(float32((hiU - loU) / w))
+	dV := av.Sub(point.Times(float32(wv))).Times(float32((hiV - loV) / w))
+	return point, dU, dV
+}
+
+func (s nurbsSurface) normal(u, v float64) m.Vector {
+	return surfaceNormal(func(u, v float64) (m.Vector, m.Vector) {
+		_, dU, dV := s.derivatives(u, v)
+		return dU, dV
+	}, u, v)
+}
+
+// samples is number of triangles in one dimension of the surface,
+// for samples*samples amount of triangles in total
+func (s nurbsSurface) Triangulate(samples int, mat m.Material) m.Object {
+	return triangulateGrid(s, samples, mat)
+}
+
+func (s nurbsSurface) TriangulateWithNormalMapping(samples int, baseMat m.Material) m.Object {
+	return triangulateGridWithNormalMapping(s, samples, baseMat)
+}
+
+func (s nurbsSurface) TriangulateAdaptive(tol Tolerance, mat m.Material) m.Object {
+	return triangulateAdaptive(s, tol, mat)
+}
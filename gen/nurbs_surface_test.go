@@ -0,0 +1,78 @@
+package gen
+
+import (
+	"math"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestNURBSSurfaceEqualsBicubicPatch(t *testing.T) {
+	points := flatPoints(0)
+	for i, p := range points {
+		points[i].Z = p.X*p.Y - p.X*p.X/2
+	}
+	weights := make([]float64, 16)
+	for i := range weights {
+		weights[i] = 1
+	}
+	knots := []float64{0, 0, 0, 0, 1, 1, 1, 1}
+	nurbs, err := NewNURBSSurface(3, 3, 4, 4, points, weights, knots, knots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := NewBicubicBezierPatch(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []float64{0, 0.25, 0.5, 1} {
+		for _, v := range []float64{0, 0.5, 0.75, 1} {
+			if got, want := nurbs.Evaluate(u, v), patch.Evaluate(u, v); !approxVector(got, want) {
+				t.Errorf("(%f,%f): got %v want %v", u, v, got, want)
+			}
+			if got, want := nurbs.normal(u, v), patch.normal(u, v); !approxVector(got, want) {
+				t.Errorf("(%f,%f): normal got %v want %v", u, v, got, want)
+			}
+		}
+	}
+}
+
+func TestNURBSSphere(t *testing.T) {
+	center := m.Vector{1, -1, 2}
+	sphere := NewNURBSSphere(center, 3)
+	for i := 0; i <= 8; i++ {
+		for j := 0; j <= 8; j++ {
+			u, v := float64(i)/8, float64(j)/8
+			p := sphere.Evaluate(u, v)
+			radial := m.VectorFromTo(center, p)
+			if r := radial.Length(); math.Abs(float64(r)-3) > 1e-4 {
+				t.Errorf("(%f,%f): point %v at distance %f from center", u, v, p, r)
+			}
+			if n := sphere.normal(u, v); n.Dot(radial) <= 0 || math.Abs(float64(n.Dot(radial.Normalize()))-1) > 1e-3 {
+				t.Errorf("(%f,%f): normal %v not pointing out", u, v, n)
+			}
+		}
+	}
+}
+
+func TestNURBSTorus(t *testing.T) {
+	center := m.Vector{1, -1, 2}
+	torus := NewNURBSTorus(center, 3, 1)
+	for i := 0; i <= 8; i++ {
+		for j := 0; j <= 8; j++ {
+			u, v := float64(i)/8, float64(j)/8
+			p := torus.Evaluate(u, v)
+			// center of the tube closest to p
+			flat := m.VectorFromTo(center, p)
+			flat.Y = 0
+			tube := center.Add(flat.Normalize().Times(3))
+			radial := m.VectorFromTo(tube, p)
+			if r := radial.Length(); math.Abs(float64(r)-1) > 1e-4 {
+				t.Errorf("(%f,%f): point %v at distance %f from tube", u, v, p, r)
+			}
+			if n := torus.normal(u, v); n.Dot(radial) <= 0 {
+				t.Errorf("(%f,%f): normal %v not pointing out", u, v, n)
+			}
+		}
+	}
+}
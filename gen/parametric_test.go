@@ -100,6 +100,14 @@ func compareVector(u, v m.Vector) bool {
 	return true
 }
 
+// as compareVector, but allowing for float32 rounding errors
+// instead of truncating at a fixed precision
+func approxVector(u, v m.Vector) bool {
+	var epsilon float32 = 1e-4
+	d := u.Sub(v)
+	return d.Dot(d) < epsilon*epsilon
+}
+
 func compareVectors(vl1, vl2 []m.Vector) bool {
 	if len(vl1) != len(vl2) {
 		return false
@@ -111,3 +119,26 @@ func compareVectors(vl1, vl2 []m.Vector) bool {
 	}
 	return true
 }
+
+// flatPoints are the control points of a flat bicubic patch over
+// [0,3]x[0,3] in z=0, shifted by dx; tests bend them by setting Z
+func flatPoints(dx float32) []m.Vector {
+	points := make([]m.Vector, 16)
+	for i := range points {
+		points[i] = m.Vector{float32(i%4) + dx, float32(i / 4), 0}
+	}
+	return points
+}
+
+func flatPatch(t *testing.T, dx float32) bicubicBezierPatch {
+	p, err := NewBicubicBezierPatch(flatPoints(dx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// flatGrid is the flat patch sampled n times
+func flatGrid(t *testing.T, dx float32, n int) Mesh {
+	return GridMesh(flatPatch(t, dx), n)
+}
@@ -0,0 +1,191 @@
+package gen
+
+import (
+	"fmt"
+	"math"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// a border of one of the surfaces in a patch set
+type patchBorder struct {
+	patch  int
+	border int
+}
+
+// two borders tracing the same curve, possibly in opposite directions
+type sharedBorder struct {
+	a, b     patchBorder
+	reversed bool
+}
+
+// number of points compared along borders to find shared ones
+const borderSamples = 5
+
+// TessellatePatches adaptively tessellates a set of surfaces into one mesh.
+// borders shared between surfaces (or between two borders of the same
+// surface, such as the seam of a sphere) get the same vertices on both sides,
+// so a closed set of patches results in a watertight mesh.
+// vertices in the same position are merged, normals are averaged over
+// the surfaces meeting in a vertex, and faces that collapse are dropped.
+// uvs are not kept since they differ per surface
+func TessellatePatches(patches []ParametricSurface, tol Tolerance) (Mesh, error) {
+	tessellations := make([]*adaptiveTessellation, len(patches))
+	for i, p := range patches {
+		s, ok := p.(normalSurface)
+		if !ok {
+			return Mesh{}, fmt.Errorf("Patch %d: no analytic normals for %T", i, p)
+		}
+		t := newAdaptiveTessellation(s, tol)
+		t.closeBorders()
+		tessellations[i] = t
+	}
+
+	shared, degenerate := findSharedBorders(tessellations)
+	// collapsed borders become exactly one point
+	for _, pb := range degenerate {
+		t := tessellations[pb.patch]
+		f := t.borderFunc(pb.border)
+		p := t.borderPoints[t.key(f(0))]
+		for _, i := range t.border[pb.border] {
+			t.borderPoints[t.key(f(i))] = p
+		}
+	}
+	// first make sure both sides have the same subdivision,
+	// then copy positions over so they match exactly
+	for _, sb := range shared {
+		ta, tb := tessellations[sb.a.patch], tessellations[sb.b.patch]
+		for _, i := range ta.border[sb.a.border] {
+			tb.addBorderPoint(sb.b.border, sb.mapIndex(i, ta.res))
+		}
+		for _, i := range tb.border[sb.b.border] {
+			ta.addBorderPoint(sb.a.border, sb.mapIndex(i, tb.res))
+		}
+	}
+	for _, sb := range shared {
+		ta, tb := tessellations[sb.a.patch], tessellations[sb.b.patch]
+		fa, fb := ta.borderFunc(sb.a.border), tb.borderFunc(sb.b.border)
+		for _, i := range ta.border[sb.a.border] {
+			p := ta.borderPoints[ta.key(fa(i))]
+			tb.borderPoints[tb.key(fb(sb.mapIndex(i, ta.res)))] = p
+		}
+	}
+
+	mesh := Mesh{}
+	indices := map[m.Vector]int{}
+	for _, t := range tessellations {
+		local := t.mesh()
+		remap := make([]int, len(local.Vertices))
+		for i, v := range local.Vertices {
+			index, ok := indices[v]
+			if !ok {
+				index = len(mesh.Vertices)
+				indices[v] = index
+				mesh.Vertices = append(mesh.Vertices, v)
+				mesh.Normals = append(mesh.Normals, m.Vector{})
+			}
+			mesh.Normals[index] = mesh.Normals[index].Add(local.Normals[i])
+			remap[i] = index
+		}
+		for _, f := range local.Faces {
+			face := [3]int{remap[f[0]], remap[f[1]], remap[f[2]]}
+			if face[0] == face[1] || face[1] == face[2] || face[2] == face[0] {
+				continue
+			}
+			mesh.Faces = append(mesh.Faces, face)
+		}
+	}
+	for i, n := range mesh.Normals {
+		mesh.Normals[i] = n.Normalize()
+	}
+	return mesh, nil
+}
+
+// closeBorders adds all quadtree vertices on the border to the border polyline,
+// so that nothing is snapped and every border vertex can be shared
+func (a *adaptiveTessellation) closeBorders() {
+	lines := [4]map[int]bool{a.rows[0], a.cols[a.res], a.rows[a.res], a.cols[0]}
+	for b, line := range lines {
+		for i := range line {
+			a.addBorderPoint(b, i)
+		}
+	}
+}
+
+func (sb sharedBorder) mapIndex(i, res int) int {
+	if sb.reversed {
+		return res - i
+	}
+	return i
+}
+
+// findSharedBorders compares points sampled along each border
+// with those of all other borders. borders collapsed into a single point,
+// like the poles of a sphere, are returned separately
+func findSharedBorders(tessellations []*adaptiveTessellation) ([]sharedBorder, []patchBorder) {
+	borders := []patchBorder{}
+	samples := [][]m.Vector{}
+	var min, max m.Vector
+	for i, t := range tessellations {
+		for b := 0; b < 4; b++ {
+			f := t.borderFunc(b)
+			points := make([]m.Vector, borderSamples)
+			for j := range points {
+				points[j] = t.evaluate(f(j * t.res / (borderSamples - 1)))
+				if len(samples) == 0 && j == 0 {
+					min, max = points[j], points[j]
+				}
+				min = m.Vector{minf(min.X, points[j].X), minf(min.Y, points[j].Y), minf(min.Z, points[j].Z)}
+				max = m.Vector{maxf(max.X, points[j].X), maxf(max.Y, points[j].Y), maxf(max.Z, points[j].Z)}
+			}
+			borders = append(borders, patchBorder{patch: i, border: b})
+			samples = append(samples, points)
+		}
+	}
+	epsilon := 1e-5 * m.VectorFromTo(min, max).Length()
+
+	matches := func(p, q []m.Vector, reversed bool) bool {
+		for j := range p {
+			k := j
+			if reversed {
+				k = len(q) - 1 - j
+			}
+			if m.VectorFromTo(p[j], q[k]).Length() > epsilon {
+				return false
+			}
+		}
+		return true
+	}
+	degenerate := func(p []m.Vector) bool {
+		for _, q := range p {
+			if m.VectorFromTo(p[0], q).Length() > epsilon {
+				return false
+			}
+		}
+		return true
+	}
+
+	shared, collapsed := []sharedBorder{}, []patchBorder{}
+	for i := range borders {
+		if degenerate(samples[i]) {
+			collapsed = append(collapsed, borders[i])
+			continue
+		}
+		for j := i + 1; j < len(borders); j++ {
+			for _, reversed := range []bool{false, true} {
+				if matches(samples[i], samples[j], reversed) {
+					shared = append(shared, sharedBorder{a: borders[i], b: borders[j], reversed: reversed})
+				}
+			}
+		}
+	}
+	return shared, collapsed
+}
+
+func minf(a, b float32) float32 {
+	return float32(math.Min(float64(a), float64(b)))
+}
+
+func maxf(a, b float32) float32 {
+	return float32(math.Max(float64(a), float64(b)))
+}
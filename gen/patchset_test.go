@@ -0,0 +1,62 @@
+package gen
+
+import (
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// edgeCount returns how many faces use each edge of the mesh
+func edgeCount(mesh Mesh) map[[2]int]int {
+	edges := map[[2]int]int{}
+	for _, f := range mesh.Faces {
+		for i := 0; i < 3; i++ {
+			a, b := f[i], f[(i+1)%3]
+			if a > b {
+				a, b = b, a
+			}
+			edges[[2]int{a, b}]++
+		}
+	}
+	return edges
+}
+
+func TestTessellatePatchesSphereIsClosed(t *testing.T) {
+	sphere := NewNURBSSphere(m.Vector{}, 1)
+	mesh, err := TessellatePatches([]ParametricSurface{sphere}, Tolerance{ChordalError: 0.01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for e, n := range edgeCount(mesh) {
+		if n != 2 {
+			t.Errorf("edge %v between %v and %v used by %d faces", e, mesh.Vertices[e[0]], mesh.Vertices[e[1]], n)
+		}
+	}
+}
+
+func TestTessellatePatchesSharedBorder(t *testing.T) {
+	bumpy := flatPoints(3)
+	for i := range bumpy {
+		// mirrored, so the shared border runs in the same direction
+		// but the patches disagree on which side it is
+		bumpy[i].Y = 3 - bumpy[i].Y
+		if i%4 != 0 {
+			bumpy[i].Z = float32(i%3) * 2
+		}
+	}
+	a := flatPatch(t, 0)
+	b, _ := NewBicubicBezierPatch(bumpy)
+	mesh, err := TessellatePatches([]ParametricSurface{a, b}, Tolerance{ChordalError: 0.01, MaxDepth: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for e, n := range edgeCount(mesh) {
+		p, q := mesh.Vertices[e[0]], mesh.Vertices[e[1]]
+		if n == 1 && p.X == 3 && q.X == 3 && p.Z == 0 && q.Z == 0 {
+			t.Errorf("edge between %v and %v on shared border used by one face", p, q)
+		}
+		if n > 2 {
+			t.Errorf("edge between %v and %v used by %d faces", p, q, n)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package gen
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// ReliefObject bump maps the mesh with a height map, such as
+// GrayScottResult.HeightMap, stretched over its uvs. strength is the height
+// in world units of white over black. normals are interpolated like
+// smoothShadingMaterial if the mesh has them, otherwise each triangle is flat.
+// the height map wraps around, so tiling textures have no seams
+// NOTE: one material per triangle, see sphere.NormalMappedSphere
+func (mesh Mesh) ReliefObject(mat m.Material, height image.Image, strength float64) (m.Object, error) {
+	if len(mesh.UVs) != len(mesh.Vertices) {
+		return nil, fmt.Errorf("Relief needs a uv per vertex: got %d uvs for %d vertices", len(mesh.UVs), len(mesh.Vertices))
+	}
+	if height.Bounds().Empty() {
+		return nil, fmt.Errorf("Relief height map is empty")
+	}
+	triangles := make([]m.Triangle, len(mesh.Faces))
+	for i, f := range mesh.Faces {
+		p0, p1, p2 := mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]]
+		triangles[i] = m.NewTriangle(p0, p1, p2, mesh.reliefMaterial(f, mat, height, strength))
+	}
+	return m.NewTriangleComplexObject(triangles), nil
+}
+
+func (mesh Mesh) reliefMaterial(f [3]int, mat m.Material, height image.Image, strength float64) m.Material {
+	p0, p1, p2 := mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]]
+	e1, e2 := m.VectorFromTo(p0, p1), m.VectorFromTo(p0, p2)
+	faceNormal := e1.Cross(e2).Normalize()
+	n0, n1, n2 := faceNormal, faceNormal, faceNormal
+	if len(mesh.Normals) == len(mesh.Vertices) {
+		n0, n1, n2 = mesh.Normals[f[0]], mesh.Normals[f[1]], mesh.Normals[f[2]]
+	}
+	uv0, uv1, uv2 := mesh.UVs[f[0]], mesh.UVs[f[1]], mesh.UVs[f[2]]
+	du1, dv1 := float64(uv1.X-uv0.X), float64(uv1.Y-uv0.Y)
+	du2, dv2 := float64(uv2.X-uv0.X), float64(uv2.Y-uv0.Y)
+
+	// derivatives of the position along u and v over this triangle,
+	// divided by their squared length so a slope in the height map
+	// becomes a slope in world units
+	var pu, pv m.Vector
+	if det := du1*dv2 - du2*dv1; det != 0 {
+		pu = e1.Times(float32(dv2 / det)).Sub(e2.Times(float32(dv1 / det)))
+		pv = e2.Times(float32(du1 / det)).Sub(e1.Times(float32(du2 / det)))
+		if l := pu.Dot(pu); l > 0 {
+			pu = pu.Times(1 / l)
+		}
+		if l := pv.Dot(pv); l > 0 {
+			pv = pv.Times(1 / l)
+		}
+	}
+
+	coords := barycentric(p0, p1, p2)
+	bounds := height.Bounds()
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	heightAt := func(x, y int) float64 {
+		x = bounds.Min.X + ((x%bounds.Dx())+bounds.Dx())%bounds.Dx()
+		y = bounds.Min.Y + ((y%bounds.Dy())+bounds.Dy())%bounds.Dy()
+		return float64(color.Gray16Model.Convert(height.At(x, y)).(color.Gray16).Y) / 0xffff
+	}
+	return &m.NormalMappingMaterial{
+		WrappedMaterial: mat,
+		NormalFunc: func(si *m.SurfaceInteraction) m.Vector {
+			b, ok := coords(si.UntransformedPoint)
+			if !ok {
+				return n0
+			}
+			n := interpolate(b, n0, n1, n2).Normalize()
+			uv := interpolate(b, uv0, uv1, uv2)
+			u, v := float64(uv.X), float64(uv.Y)
+			x, y := int(u*w), int(v*h)
+			// slopes per unit of u and v by central differences
+			hu := (heightAt(x+1, y) - heightAt(x-1, y)) * w / 2 * strength
+			hv := (heightAt(x, y+1) - heightAt(x, y-1)) * h / 2 * strength
+			return n.Sub(pu.Times(float32(hu))).Sub(pv.Times(float32(hv))).Normalize()
+		},
+	}
+}
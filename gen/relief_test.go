@@ -0,0 +1,47 @@
+package gen
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestReliefMaterial(t *testing.T) {
+	// flat square over [0,3]x[0,3] in z=0 with uvs going from 0 to 1
+	mesh := flatGrid(t, 0, 1)
+	mesh.Normals = nil
+	// height rising from black to white along u
+	w := 64
+	height := image.NewGray16(image.Rect(0, 0, w, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < w; x++ {
+			height.SetGray16(x, y, color.Gray16{uint16(x * 0xffff / w)})
+		}
+	}
+	mat := mesh.reliefMaterial(mesh.Faces[0], nil, height, 1).(*m.NormalMappingMaterial)
+	n := mat.NormalFunc(&m.SurfaceInteraction{UntransformedPoint: m.Vector{2, 1, 0}})
+	// a slope of 1 over 3 units in x
+	want := m.Vector{-1, 0, 3}.Normalize()
+	if math.Abs(float64(n.Sub(want).Length())) > 1e-3 {
+		t.Errorf("got %v want %v", n, want)
+	}
+}
+
+func TestReliefObjectInvalid(t *testing.T) {
+	mesh := flatGrid(t, 0, 1)
+	height := image.NewGray16(image.Rect(0, 0, 4, 4))
+	if _, err := mesh.ReliefObject(nil, height, 1); err != nil {
+		t.Fatal(err)
+	}
+	noUVs := mesh
+	noUVs.UVs = nil
+	if _, err := noUVs.ReliefObject(nil, height, 1); err == nil {
+		t.Error("mesh without uvs: expected error")
+	}
+	if _, err := mesh.ReliefObject(nil, image.NewGray16(image.Rectangle{}), 1); err == nil {
+		t.Error("empty height map: expected error")
+	}
+}
@@ -0,0 +1,176 @@
+package gen
+
+import (
+	"fmt"
+	"math"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+type Continuity uint8
+
+const (
+	// segments only share their endpoints
+	ContinuityC0 Continuity = iota
+	// tangents on both sides of a joint are equal
+	ContinuityC1
+	// tangents on both sides of a joint point in the same direction
+	ContinuityG1
+)
+
+// composite bezier spline: cubic segments chained over t in [0-1]
+// each segment takes up an equal part of the parameter range,
+// so with n segments segment i is traced for t in [i/n, (i+1)/n]
+type bezierSpline struct {
+	parametricFunction
+	segments []cubicBezierCurve
+}
+
+// NewBezierSpline joins cubic bezier segments into one curve.
+// the start of each segment is moved onto the end of the previous one,
+// and for C1 or G1 continuity the control points on either side
+// of each joint are adjusted so the tangents match up.
+// a G1 joint where a handle has zero length, or where the curve
+// turns back on itself, has no direction to share and stays C0
+func NewBezierSpline(segments []cubicBezierCurve, c Continuity) (bezierSpline, error) {
+	if len(segments) == 0 {
+		return bezierSpline{}, fmt.Errorf("Bezier spline without segments")
+	}
+	points := make([][]m.Vector, len(segments))
+	for i, s := range segments {
+		points[i] = make([]m.Vector, 4)
+		copy(points[i], s.controlPoints)
+	}
+	for i := 1; i < len(points); i++ {
+		prev, next := points[i-1], points[i]
+		next[0] = prev[3]
+		in := m.VectorFromTo(prev[2], prev[3])
+		out := m.VectorFromTo(next[0], next[1])
+		switch c {
+		case ContinuityC1:
+			// average of incoming and outgoing tangent
+			d := in.Add(out).Times(0.5)
+			prev[2] = prev[3].Sub(d)
+			next[1] = next[0].Add(d)
+		case ContinuityG1:
+			// average direction, but keep the lengths of both handles
+			if in.Length() == 0 || out.Length() == 0 {
+				continue
+			}
+			sum := in.Normalize().Add(out.Normalize())
+			if sum.Length() == 0 {
+				continue
+			}
+			dir := sum.Normalize()
+			prev[2] = prev[3].Sub(dir.Times(in.Length()))
+			next[1] = next[0].Add(dir.Times(out.Length()))
+		}
+	}
+	joined := make([]cubicBezierCurve, len(points))
+	for i, p := range points {
+		joined[i] = NewCubicBezierCurve(p[0], p[1], p[2], p[3])
+	}
+	s := bezierSpline{segments: joined}
+	s.parametricFunction = NewParametricFunction(func(t float64) m.Vector {
+		i, local := s.segment(t)
+		return s.segments[i].Vector(local)
+	})
+	return s, nil
+}
+
+// segment returns the index of the segment t falls in
+// and the local parameter within that segment
+func (s bezierSpline) segment(t float64) (int, float64) {
+	n := float64(len(s.segments))
+	i := int(math.Floor(t * n))
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(s.segments) {
+		i = len(s.segments) - 1
+	}
+	return i, t*n - float64(i)
+}
+
+// derivatives wrt global t pick up a factor n per derivation (chain rule)
+func (s bezierSpline) Derivative() ParametricFunction {
+	n := float32(len(s.segments))
+	return NewParametricFunction(func(t float64) m.Vector {
+		i, local := s.segment(t)
+		return s.segments[i].Derivative().Vector(local).Times(n)
+	})
+}
+
+func (s bezierSpline) SecondDerivative() ParametricFunction {
+	n := float32(len(s.segments))
+	return NewParametricFunction(func(t float64) m.Vector {
+		i, local := s.segment(t)
+		return s.segments[i].SecondDerivative().Vector(local).Times(n * n)
+	})
+}
+
+// parameterization of catmull-rom splines:
+// knot spacing between points is distance^alpha
+const (
+	CatmullRomUniform     = 0.0
+	CatmullRomCentripetal = 0.5
+	CatmullRomChordal     = 1.0
+)
+
+// NewCatmullRomSpline returns a spline passing through all points,
+// reaching points[i] at t = i/(len(points)-1).
+// alpha sets the parameterization; centripetal (0.5) avoids cusps
+// and self-intersections within a segment.
+// each segment is converted to its equivalent cubic bezier curve,
+// so derivatives are exact. joints are C1 for uniform splines
+// and G1 otherwise, since each segment spans an equal part of t.
+// the curve is extended past the first and last point
+// by mirroring their neighbours. a point repeating the one before it
+// is dropped first, leaving no zero length segments; at least 2 points
+// must remain
+func NewCatmullRomSpline(points []m.Vector, alpha float64) (bezierSpline, error) {
+	distinct := make([]m.Vector, 0, len(points))
+	for i, p := range points {
+		if i > 0 && p == points[i-1] {
+			continue
+		}
+		distinct = append(distinct, p)
+	}
+	points = distinct
+	n := len(points)
+	if n < 2 {
+		return bezierSpline{}, fmt.Errorf("Catmull-Rom spline needs at least 2 distinct points, got %d", n)
+	}
+	extended := make([]m.Vector, n+2)
+	copy(extended[1:], points)
+	extended[0] = points[0].Add(m.VectorFromTo(points[1], points[0]))
+	extended[n+1] = points[n-1].Add(m.VectorFromTo(points[n-2], points[n-1]))
+
+	segments := make([]cubicBezierCurve, n-1)
+	for i := 0; i < n-1; i++ {
+		p0, p1, p2, p3 := extended[i], extended[i+1], extended[i+2], extended[i+3]
+		d01 := knotDistance(p0, p1, alpha)
+		d12 := knotDistance(p1, p2, alpha)
+		d23 := knotDistance(p2, p3, alpha)
+		// tangents at p1 and p2 scaled to the segment, from the
+		// derivative of the barry-goldman pyramid formulation
+		m1 := m.VectorFromTo(p0, p1).Times(1 / d01).
+			Sub(m.VectorFromTo(p0, p2).Times(1 / (d01 + d12))).
+			Add(m.VectorFromTo(p1, p2).Times(1 / d12)).Times(d12)
+		m2 := m.VectorFromTo(p1, p2).Times(1 / d12).
+			Sub(m.VectorFromTo(p1, p3).Times(1 / (d12 + d23))).
+			Add(m.VectorFromTo(p2, p3).Times(1 / d23)).Times(d12)
+		segments[i] = NewCubicBezierCurve(p1, p1.Add(m1.Times(1.0/3.0)), p2.Sub(m2.Times(1.0/3.0)), p2)
+	}
+	return NewBezierSpline(segments, ContinuityC0)
+}
+
+// coinciding points would give a zero knot interval,
+// so those are treated as uniform instead
+func knotDistance(p, q m.Vector, alpha float64) float32 {
+	d := float32(math.Pow(float64(m.VectorFromTo(p, q).Length()), alpha))
+	if d < 1e-6 {
+		return 1
+	}
+	return d
+}
@@ -0,0 +1,185 @@
+package gen
+
+import (
+	"math"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestBezierSpline(t *testing.T) {
+	first := NewCubicBezierCurve(m.Vector{0, 0, 0}, m.Vector{1, 1, 0}, m.Vector{2, 1, 0}, m.Vector{3, 0, 0})
+	second := NewCubicBezierCurve(m.Vector{3, 0, 0}, m.Vector{5, 0, 0}, m.Vector{5, -2, 0}, m.Vector{6, -2, 1})
+	for i, tt := range []struct {
+		continuity Continuity
+		check      func(in, out m.Vector) bool
+	}{
+		{
+			continuity: ContinuityC0,
+			check:      func(in, out m.Vector) bool { return true },
+		},
+		{
+			continuity: ContinuityC1,
+			check:      func(in, out m.Vector) bool { return approxVector(in, out) },
+		},
+		{
+			continuity: ContinuityG1,
+			check:      func(in, out m.Vector) bool { return approxVector(in.Normalize(), out.Normalize()) },
+		},
+	} {
+		spline, err := NewBezierSpline([]cubicBezierCurve{first, second}, tt.continuity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := spline.Vector(0), first.Vector(0); !approxVector(got, want) {
+			t.Errorf("%d): start got %v want %v", i, got, want)
+		}
+		if got, want := spline.Vector(0.5), first.Vector(1); !approxVector(got, want) {
+			t.Errorf("%d): joint got %v want %v", i, got, want)
+		}
+		if got, want := spline.Vector(1), second.Vector(1); !approxVector(got, want) {
+			t.Errorf("%d): end got %v want %v", i, got, want)
+		}
+		in := spline.Derivative().Vector(0.5 - 1e-6)
+		out := spline.Derivative().Vector(0.5)
+		if !tt.check(in, out) {
+			t.Errorf("%d): tangents at joint %v and %v not continuous", i, in, out)
+		}
+	}
+}
+
+func TestBezierSplineInvalid(t *testing.T) {
+	if _, err := NewBezierSpline(nil, ContinuityC0); err == nil {
+		t.Error("expected error for spline without segments")
+	}
+	// the second segment starts with a zero length handle
+	first := NewCubicBezierCurve(m.Vector{0, 0, 0}, m.Vector{1, 1, 0}, m.Vector{2, 1, 0}, m.Vector{3, 0, 0})
+	second := NewCubicBezierCurve(m.Vector{3, 0, 0}, m.Vector{3, 0, 0}, m.Vector{5, -2, 0}, m.Vector{6, -2, 1})
+	spline, err := NewBezierSpline([]cubicBezierCurve{first, second}, ContinuityG1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range spline.segments[0].controlPoints {
+		if p != p {
+			t.Fatalf("NaN control point %v", p)
+		}
+	}
+	if got, want := spline.segments[0].controlPoints[2], (m.Vector{2, 1, 0}); got != want {
+		t.Errorf("handle moved to %v, want %v", got, want)
+	}
+}
+
+func TestCatmullRomSpline(t *testing.T) {
+	points := []m.Vector{{0, 0, 0}, {1, 2, 0}, {3, 2, 1}, {4, 0, 1}, {4, -1, 3}}
+	n := float64(len(points) - 1)
+	for _, alpha := range []float64{CatmullRomUniform, CatmullRomCentripetal, CatmullRomChordal} {
+		spline, err := NewCatmullRomSpline(points, alpha)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, p := range points {
+			if got := spline.Vector(float64(i) / n); !approxVector(got, p) {
+				t.Errorf("alpha %f: point %d got %v want %v", alpha, i, got, p)
+			}
+		}
+		for i := 1; i < len(points)-1; i++ {
+			joint := float64(i) / n
+			in := spline.Derivative().Vector(joint - 1e-6).Normalize()
+			out := spline.Derivative().Vector(joint).Normalize()
+			if !approxVector(in, out) {
+				t.Errorf("alpha %f: tangents at joint %d %v and %v not continuous", alpha, i, in, out)
+			}
+		}
+	}
+	// uniform catmull-rom tangent is half the vector between neighbours,
+	// times the number of segments because of the global parameterization
+	uniform, _ := NewCatmullRomSpline(points, CatmullRomUniform)
+	want := m.VectorFromTo(points[0], points[2]).Times(0.5 * float32(n))
+	if got := uniform.Derivative().Vector(1 / n); !approxVector(got, want) {
+		t.Errorf("uniform tangent got %v want %v", got, want)
+	}
+}
+
+func TestCatmullRomSplineDuplicates(t *testing.T) {
+	if _, err := NewCatmullRomSpline([]m.Vector{{1, 2, 3}}, CatmullRomCentripetal); err == nil {
+		t.Error("expected error for a single point")
+	}
+	if _, err := NewCatmullRomSpline([]m.Vector{{1, 2, 3}, {1, 2, 3}}, CatmullRomCentripetal); err == nil {
+		t.Error("expected error for a repeated single point")
+	}
+	points := []m.Vector{{0, 0, 0}, {1, 2, 0}, {1, 2, 0}, {3, 2, 1}}
+	spline, err := NewCatmullRomSpline(points, CatmullRomCentripetal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spline.segments) != 2 {
+		t.Fatalf("got %d segments want 2", len(spline.segments))
+	}
+	for _, tt := range []float64{0, 0.25, 0.5, 0.75, 1} {
+		if p := spline.Vector(tt); p != p {
+			t.Errorf("t=%f: got %v", tt, p)
+		}
+	}
+}
+
+func TestBSplineEqualsBezier(t *testing.T) {
+	p0, p1, p2, p3 := m.Vector{1, 2, 3}, m.Vector{4, -5, 6}, m.Vector{7, 8, -9}, m.Vector{10, 11, 12}
+	cubic := NewCubicBezierCurve(p0, p1, p2, p3)
+	bspline, err := NewUniformBSplineCurve(3, []m.Vector{p0, p1, p2, p3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []float64{0.0, 0.25, 0.5, 0.75, 1.0} {
+		if got, want := bspline.Vector(tt), cubic.Vector(tt); !approxVector(got, want) {
+			t.Errorf("t=%f: got %v want %v", tt, got, want)
+		}
+		if got, want := bspline.Derivative().Vector(tt), cubic.Derivative().Vector(tt); !approxVector(got, want) {
+			t.Errorf("t=%f: derivative got %v want %v", tt, got, want)
+		}
+		if got, want := bspline.SecondDerivative().Vector(tt), cubic.SecondDerivative().Vector(tt); !approxVector(got, want) {
+			t.Errorf("t=%f: second derivative got %v want %v", tt, got, want)
+		}
+	}
+}
+
+func TestNURBSCircle(t *testing.T) {
+	center := m.Vector{1, 2, 3}
+	circle := NewNURBSCircle(center, 2, m.Vector{1, 0, 0}, m.Vector{0, 0, 1})
+	for i := 0; i <= 20; i++ {
+		tt := float64(i) / 20.0
+		p := circle.Vector(tt)
+		if r := m.VectorFromTo(center, p).Length(); math.Abs(float64(r)-2) > 1e-4 {
+			t.Errorf("t=%f: point %v at distance %f from center", tt, p, r)
+		}
+		// tangent is perpendicular to the radius
+		d := circle.Derivative().Vector(tt)
+		if dot := d.Normalize().Dot(m.VectorFromTo(center, p).Normalize()); math.Abs(float64(dot)) > 1e-4 {
+			t.Errorf("t=%f: tangent %v not perpendicular to radius", tt, d)
+		}
+	}
+}
+
+func TestNURBSInvalid(t *testing.T) {
+	points := []m.Vector{{0, 0, 0}, {1, 1, 0}, {2, 0, 0}}
+	for i, tt := range []struct {
+		degree  int
+		weights []float64
+		knots   []float64
+	}{
+		{degree: 0, weights: []float64{1, 1, 1}, knots: []float64{0, 0.5, 1, 1}},
+		{degree: 3, weights: []float64{1, 1, 1}, knots: []float64{0, 0, 0, 0, 1, 1, 1}},
+		{degree: 2, weights: []float64{1, 1}, knots: []float64{0, 0, 0, 1, 1, 1}},
+		{degree: 2, weights: []float64{1, -1, 1}, knots: []float64{0, 0, 0, 1, 1, 1}},
+		{degree: 2, weights: []float64{1, 1, 1}, knots: []float64{0, 0, 1, 1, 1}},
+		{degree: 2, weights: []float64{1, 1, 1}, knots: []float64{0, 0, 1, 0, 1, 1}},
+	} {
+		if _, err := NewNURBSCurve(tt.degree, points, tt.weights, tt.knots); err == nil {
+			t.Errorf("%d): expected error", i)
+		}
+	}
+	for _, degree := range []int{-2, 0, 3} {
+		if _, err := NewUniformBSplineCurve(degree, points); err == nil {
+			t.Errorf("uniform degree %d: expected error", degree)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package gen
+
+import (
+	"math"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// a surface with analytic normals, u and v in [0-1]
+type normalSurface interface {
+	Evaluate(u, v float64) m.Vector
+	normal(u, v float64) m.Vector
+}
+
+// surfaceNormal returns the normalized cross product of the partial derivatives.
+// at degenerate points such as the poles of a sphere one of the
+// partial derivatives vanishes; there we take the normal slightly
+// inside the surface instead
+func surfaceNormal(partials func(u, v float64) (m.Vector, m.Vector), u, v float64) m.Vector {
+	dU, dV := partials(u, v)
+	n := dU.Cross(dV)
+	if n.Length() > 1e-6 {
+		return n.Normalize()
+	}
+	const epsilon = 1e-4
+	u, v = u+epsilon*math.Copysign(1, 0.5-u), v+epsilon*math.Copysign(1, 0.5-v)
+	dU, dV = partials(u, v)
+	return dU.Cross(dV).Normalize()
+}
+
+// samples is number of triangles in one dimension of the surface,
+// for samples*samples amount of triangles in total
+func triangulateGrid(s ParametricSurface, samples int, mat m.Material) m.Object {
+	vertices := make([]m.Vector, (samples+1)*(samples+1))
+	f64s := float64(samples)
+	for v := 0; v <= samples; v++ {
+		for u := 0; u <= samples; u++ {
+			vertices[v*(samples+1)+u] = s.Evaluate(float64(u)/f64s, float64(v)/f64s)
+		}
+	}
+	return m.NewGridTriangleMesh(samples, samples, vertices, nil, nil, mat)
+}
+
+// as triangulateGrid, but storing normals and uv coordinates per vertex
+// so they can be interpolated when rendering
+func triangulateGridWithNormalMapping(s normalSurface, samples int, baseMat m.Material) m.Object {
+	mat := m.InterpolatedNormalMappingMaterial(baseMat)
+	vertices := make([]m.Vector, (samples+1)*(samples+1))
+	normals := make([]m.Vector, (samples+1)*(samples+1))
+	uvs := make([]m.Vector, (samples+1)*(samples+1))
+	f64s := float64(samples)
+	for intv := 0; intv <= samples; intv++ {
+		for intu := 0; intu <= samples; intu++ {
+			u, v := float64(intu)/f64s, float64(intv)/f64s
+			vertices[intv*(samples+1)+intu] = s.Evaluate(u, v)
+			normals[intv*(samples+1)+intu] = s.normal(u, v)
+			uvs[intv*(samples+1)+intu] = m.Vector{float32(u), float32(v), 0}
+		}
+	}
+	return m.NewGridTriangleMesh(samples, samples, vertices, normals, uvs, mat)
+}
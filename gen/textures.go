@@ -3,8 +3,10 @@ package gen
 import (
     "image"
     "image/color"
+    "math"
     "math/rand"
-    "time"
+    "runtime"
+    "sync"
 )
 
 type GrayScottInput struct {
@@ -15,16 +17,98 @@ type GrayScottInput struct {
     KillRate float64
     DiffRateA float64
     DiffRateB float64
+    // number of goroutines sharing the work, defaults to the number of cpus
+    Workers int
+    // random source for the initial state: Rand if set,
+    // otherwise a new source from Seed, so the same input gives the same image
+    Seed int64
+    Rand *rand.Rand
+    // initial concentration of B, defaults to RandomSeeding(1/50)
+    Initial GrayScottInitial
+    // if set, these replace the rates above with a value per cell
+    FeedField GrayScottField
+    KillField GrayScottField
+    DiffAField GrayScottField
+    DiffBField GrayScottField
 }
 
-type coord struct {
-    X int
-    Y int
+// GrayScottField returns a rate varying over the texture,
+// with x and y going from 0 to 1 over its width and height
+type GrayScottField func(x, y float64) float64
+
+// ImageField maps the brightness of an image from min (black) to max (white).
+// the image is stretched over the texture
+func ImageField(img image.Image, min, max float64) GrayScottField {
+    bounds := img.Bounds()
+    return func(x, y float64) float64 {
+        ix := bounds.Min.X + int(x*float64(bounds.Dx()))
+        iy := bounds.Min.Y + int(y*float64(bounds.Dy()))
+        gray := color.Gray16Model.Convert(img.At(ix, iy)).(color.Gray16)
+        return min + (max-min)*float64(gray.Y)/0xffff
+    }
+}
+
+// LinearField goes from min at x=0 to max at x=1, and similarly in y
+// if vertical is set; a gradient from one pattern to another
+func LinearField(min, max float64, vertical bool) GrayScottField {
+    return func(x, y float64) float64 {
+        if vertical {
+            return min + (max-min)*y
+        }
+        return min + (max-min)*x
+    }
+}
+
+// GrayScottInitial returns the initial concentration of B at (x, y)
+// in a grid of w by h; A starts at 1 everywhere.
+// it is called for every cell, row by row
+type GrayScottInitial func(x, y, w, h int, rnd *rand.Rand) float64
+
+// RandomSeeding puts B in a random fraction rate of the cells
+func RandomSeeding(rate float64) GrayScottInitial {
+    return func(x, y, w, h int, rnd *rand.Rand) float64 {
+        if rnd.Float64() < rate {
+            return 1.0
+        }
+        return 0.0
+    }
 }
 
-type ab struct {
-    A float64
-    B float64
+// MaskSeeding puts B where the mask is dark, in proportion to
+// its darkness. the mask is stretched to the size of the grid
+func MaskSeeding(mask image.Image) GrayScottInitial {
+    bounds := mask.Bounds()
+    return func(x, y, w, h int, rnd *rand.Rand) float64 {
+        mx := bounds.Min.X + x*bounds.Dx()/w
+        my := bounds.Min.Y + y*bounds.Dy()/h
+        gray := color.Gray16Model.Convert(mask.At(mx, my)).(color.Gray16)
+        return 1.0 - float64(gray.Y)/0xffff
+    }
+}
+
+// CenterBlob puts B in a disc of radius r cells in the middle of the grid
+func CenterBlob(r int) GrayScottInitial {
+    return func(x, y, w, h int, rnd *rand.Rand) float64 {
+        dx, dy := x-w/2, y-h/2
+        if dx*dx+dy*dy <= r*r {
+            return 1.0
+        }
+        return 0.0
+    }
+}
+
+// Stripes puts B in vertical stripes width cells wide, every period cells.
+// a period below 1 is taken as 1
+func Stripes(period, width int) GrayScottInitial {
+    if period < 1 {
+        period = 1
+    }
+    return func(x, y, w, h int, rnd *rand.Rand) float64 {
+        if x%period < width {
+            return 1.0
+        }
+        return 0.0
+    }
 }
 
 const (
@@ -34,99 +118,532 @@ const (
     seedRate = 1.0 / 50.0
 )
 
-// grid based approximation to reaction-diffusion using Gray-Scott model
+// grid based approximation to reaction-diffusion using Gray-Scott model,
+// drawn as B from white to black; see RunGrayScott for other outputs
 func GrayScott(input GrayScottInput) image.Image {
-    w := input.Width
-    h := input.Height
-    f := input.FeedRate
-    k := input.KillRate
-    da := input.DiffRateA
-    db := input.DiffRateB
-    m := map[coord]ab{}
+    return RunGrayScott(input).Image(ChannelB, nil)
+}
+
+// GrayScottResult holds the concentrations of A and B
+// after running the model, row by row
+type GrayScottResult struct {
+    Width, Height int
+    A, B []float64
+}
+
+// RunGrayScott runs the model like GrayScott but returns the concentrations
+func RunGrayScott(input GrayScottInput) GrayScottResult {
+    w, h := input.Width, input.Height
+    grid := newGrayScottGrid(w, h)
 
     // initial seeding of grid with A and B values
-    rand.Seed(time.Now().Unix())
+    rnd := input.Rand
+    if rnd == nil {
+        rnd = rand.New(rand.NewSource(input.Seed))
+    }
+    initial := input.Initial
+    if initial == nil {
+        initial = RandomSeeding(seedRate)
+    }
     for y:=0; y < h; y++ {
         for x:=0; x < w; x++ {
-            b := 0.0
-            if rand.Float64() < seedRate {
-                b = 1.0
-            }
-            m[coord{X:x, Y:y}] = ab{A:1.0, B:b}
+            grid.a[y*w+x] = 1.0
+            grid.b[y*w+x] = initial(x, y, w, h, rnd)
         }
     }
 
-    // do n iterations of grid update
-    for n:=0; n < input.Iterations; n++ {
-        m = grayScottLoop(m, w, h, f, k, da, db)
+    return grid.run(input).result()
+}
+
+// grayScottGrid holds the concentrations of A and B row by row,
+// wrapping around at the edges
+type grayScottGrid struct {
+    w, h int
+    a, b []float64
+}
+
+func newGrayScottGrid(w, h int) grayScottGrid {
+    return grayScottGrid{w: w, h: h, a: make([]float64, w*h), b: make([]float64, w*h)}
+}
+
+// rates of the Gray-Scott model, per cell if the slice is not nil
+type grayScottRates struct {
+    f, k, da, db float64
+    fs, ks, das, dbs []float64
+}
+
+func (g grayScottGrid) rates(input GrayScottInput) grayScottRates {
+    field := func(f GrayScottField) []float64 {
+        if f == nil {
+            return nil
+        }
+        values := make([]float64, g.w*g.h)
+        for y:=0; y < g.h; y++ {
+            for x:=0; x < g.w; x++ {
+                values[y*g.w+x] = f(float64(x)/float64(g.w), float64(y)/float64(g.h))
+            }
+        }
+        return values
+    }
+    return grayScottRates{
+        f: input.FeedRate,
+        k: input.KillRate,
+        da: input.DiffRateA,
+        db: input.DiffRateB,
+        fs: field(input.FeedField),
+        ks: field(input.KillField),
+        das: field(input.DiffAField),
+        dbs: field(input.DiffBField),
     }
+}
 
-    // collect image and return
-	img := image.NewRGBA(image.Rect(0, 0, w, h))
-    for c, ab := range m {
-        bw := uint8((1 - ab.B) * 255)
-        color := color.RGBA{bw, bw, bw, 255}
-        img.Set(c.X, c.Y, color)
+// run does all iterations, swapping between two grids.
+// each iteration the rows are split over the workers
+func (g grayScottGrid) run(input GrayScottInput) grayScottGrid {
+    rates := g.rates(input)
+    workers := input.Workers
+    if workers <= 0 {
+        workers = runtime.NumCPU()
     }
-    return img
+    if workers > g.h {
+        workers = g.h
+    }
+    next := newGrayScottGrid(g.w, g.h)
+    var wg sync.WaitGroup
+    for n:=0; n < input.Iterations; n++ {
+        wg.Add(workers)
+        for i:=0; i < workers; i++ {
+            y0, y1 := i*g.h/workers, (i+1)*g.h/workers
+            go func() {
+                grayScottLoop(g, next, y0, y1, rates)
+                wg.Done()
+            }()
+        }
+        wg.Wait()
+        g, next = next, g
+    }
+    return g
 }
 
+func (g grayScottGrid) result() GrayScottResult {
+    return GrayScottResult{Width: g.w, Height: g.h, A: g.a, B: g.b}
+}
 
 // A' = A + (D_a * La - AB^2 + f(1-A))dt
 // B' = B + (D_b * Lb + AB^2 - (k+f)B)dt
 // where D is diffusion rate and L the 2D laplacian,
 // which we will approximate with a laplacian matrix
 // dt is delta time which we will set to 1 (ignore)
-// TODO: variable f, k based on location
-func grayScottLoop(m map[coord]ab, w, h int, f, k, da, db float64) map[coord]ab {
-    newM := map[coord]ab{}
-    for c, cab := range m {
-        x, y := c.X, c.Y
-        a, b := cab.A, cab.B
-        la := a * centerWeight
-        lb := b * centerWeight
-        adjacents, diagonals := neighbours(m, x, y, w, h)
-        for _, n := range adjacents {
-            la += n.A * adjacentWeight
-            lb += n.B * adjacentWeight
-        }
-        for _, n := range diagonals {
-            la += n.A * diagonalWeight
-            lb += n.B * diagonalWeight
-        }
-        newa := a + ((da * la) - (a * b * b) + (f * (1.0-a)))
-        newb := b + ((db * lb) + (a * b * b) - ((k + f) * b))
-        newM[c] = ab{A:newa, B:newb}
-    }
-    return newM
-}
-
-func neighbours(m map[coord]ab, x, y, w, h int) ([]ab, []ab) {
-    xn := x+1
-    if xn == w {
-        xn = 0
-    }
-    xp := x-1
-    if x == 0 {
-        xp = w-1
-    }
-    yn := y+1
-    if yn == h {
-        yn = 0
-    }
-    yp := y-1
-    if y == 0 {
-        yp = h-1
-    }
-    return []ab{
-        m[coord{X:x, Y:yp}],
-        m[coord{X:x, Y:yn}],
-        m[coord{X:xp, Y:y}],
-        m[coord{X:xn, Y:y}],
-    }, []ab {
-        m[coord{X:xp, Y:yp}],
-        m[coord{X:xp, Y:yn}],
-        m[coord{X:xn, Y:yp}],
-        m[coord{X:xn, Y:yn}],
+// f, k and the diffusion rates can vary per cell
+// updates rows y0 up to y1 of next from g
+func grayScottLoop(g, next grayScottGrid, y0, y1 int, rates grayScottRates) {
+    w, h := g.w, g.h
+    f, k, da, db := rates.f, rates.k, rates.da, rates.db
+    for y:=y0; y < y1; y++ {
+        yp, yn := (y+h-1)%h*w, (y+1)%h*w
+        row := y*w
+        for x:=0; x < w; x++ {
+            xp, xn := x-1, x+1
+            if x == 0 {
+                xp = w-1
+            }
+            if xn == w {
+                xn = 0
+            }
+            a, b := g.a[row+x], g.b[row+x]
+            if rates.fs != nil {
+                f = rates.fs[row+x]
+            }
+            if rates.ks != nil {
+                k = rates.ks[row+x]
+            }
+            if rates.das != nil {
+                da = rates.das[row+x]
+            }
+            if rates.dbs != nil {
+                db = rates.dbs[row+x]
+            }
+            // same order of summation as the original map based version,
+            // so results do not change in the last bits
+            la := a * centerWeight
+            la += g.a[yp+x] * adjacentWeight
+            la += g.a[yn+x] * adjacentWeight
+            la += g.a[row+xp] * adjacentWeight
+            la += g.a[row+xn] * adjacentWeight
+            la += g.a[yp+xp] * diagonalWeight
+            la += g.a[yn+xp] * diagonalWeight
+            la += g.a[yp+xn] * diagonalWeight
+            la += g.a[yn+xn] * diagonalWeight
+            lb := b * centerWeight
+            lb += g.b[yp+x] * adjacentWeight
+            lb += g.b[yn+x] * adjacentWeight
+            lb += g.b[row+xp] * adjacentWeight
+            lb += g.b[row+xn] * adjacentWeight
+            lb += g.b[yp+xp] * diagonalWeight
+            lb += g.b[yn+xp] * diagonalWeight
+            lb += g.b[yp+xn] * diagonalWeight
+            lb += g.b[yn+xn] * diagonalWeight
+            newa := a + ((da * la) - (a * b * b) + (f * (1.0-a)))
+            newb := b + ((db * lb) + (a * b * b) - ((k + f) * b))
+            next.a[row+x], next.b[row+x] = newa, newb
+        }
+    }
+}
+
+// GrayScottChannel selects what to draw from a GrayScottResult
+type GrayScottChannel int
+
+const (
+    ChannelB GrayScottChannel = iota
+    ChannelA
+    // A minus B, shifted from -1..1 to 0..1
+    ChannelAB
+)
+
+// Values returns the concentrations of a channel per cell, row by row
+func (r GrayScottResult) Values(channel GrayScottChannel) []float64 {
+    switch channel {
+    case ChannelA:
+        return r.A
+    case ChannelAB:
+        values := make([]float64, len(r.A))
+        for i := range values {
+            values[i] = (r.A[i] - r.B[i] + 1) / 2
+        }
+        return values
+    }
+    return r.B
+}
+
+// Image draws a channel through a colour ramp.
+// without a ramp 0 is white and 1 is black, as GrayScott has always done
+func (r GrayScottResult) Image(channel GrayScottChannel, ramp ColorRamp) image.Image {
+    img := image.NewRGBA(image.Rect(0, 0, r.Width, r.Height))
+    for i, v := range r.Values(channel) {
+        if ramp == nil {
+            bw := uint8((1 - v) * 255)
+            img.Pix[4*i], img.Pix[4*i+1], img.Pix[4*i+2], img.Pix[4*i+3] = bw, bw, bw, 255
+            continue
+        }
+        c := ramp.At(v)
+        img.Pix[4*i], img.Pix[4*i+1], img.Pix[4*i+2], img.Pix[4*i+3] = uint8(c.R>>8), uint8(c.G>>8), uint8(c.B>>8), uint8(c.A>>8)
+    }
+    return img
+}
+
+// Gray16 draws a channel in 16 bit grey, 0 black and 1 white
+func (r GrayScottResult) Gray16(channel GrayScottChannel) *image.Gray16 {
+    img := image.NewGray16(image.Rect(0, 0, r.Width, r.Height))
+    for i, v := range r.Values(channel) {
+        img.Set(i%r.Width, i/r.Width, color.Gray16{gray16(v)})
+    }
+    return img
+}
+
+// HeightMap is like Gray16 but stretches the values from black to white
+func (r GrayScottResult) HeightMap(channel GrayScottChannel) *image.Gray16 {
+    values := r.Values(channel)
+    min, max := math.Inf(1), math.Inf(-1)
+    for _, v := range values {
+        min, max = math.Min(min, v), math.Max(max, v)
+    }
+    img := image.NewGray16(image.Rect(0, 0, r.Width, r.Height))
+    for i, v := range values {
+        h := 0.0
+        if max > min {
+            h = (v - min) / (max - min)
+        }
+        img.Set(i%r.Width, i/r.Width, color.Gray16{gray16(h)})
+    }
+    return img
+}
+
+// NormalMap treats a channel as height and returns the normals in tangent
+// space, with x along the width and y along the height of the texture,
+// encoded as rgb from -1 to 1. strength scales the slopes; the texture
+// wraps around, so the map tiles like the pattern does
+func (r GrayScottResult) NormalMap(channel GrayScottChannel, strength float64) image.Image {
+    values := r.Values(channel)
+    w, h := r.Width, r.Height
+    img := image.NewRGBA(image.Rect(0, 0, w, h))
+    for y:=0; y < h; y++ {
+        for x:=0; x < w; x++ {
+            dx := (values[y*w+(x+1)%w] - values[y*w+(x+w-1)%w]) / 2
+            dy := (values[(y+1)%h*w+x] - values[(y+h-1)%h*w+x]) / 2
+            nx, ny, nz := -strength*dx, -strength*dy, 1.0
+            l := math.Sqrt(nx*nx + ny*ny + nz*nz)
+            i := 4*(y*w+x)
+            img.Pix[i] = uint8(math.Round((nx/l + 1) / 2 * 255))
+            img.Pix[i+1] = uint8(math.Round((ny/l + 1) / 2 * 255))
+            img.Pix[i+2] = uint8(math.Round((nz/l + 1) / 2 * 255))
+            img.Pix[i+3] = 255
+        }
+    }
+    return img
+}
+
+func gray16(v float64) uint16 {
+    return uint16(math.Round(math.Max(0, math.Min(1, v)) * 0xffff))
+}
+
+// ColorStop is the colour of a ramp at a value
+type ColorStop struct {
+    At float64
+    Color color.Color
+}
+
+// ColorRamp is a gradient through stops ordered by value.
+// values outside the stops get the colour of the nearest one
+type ColorRamp []ColorStop
+
+// At interpolates linearly between the two stops around v
+func (ramp ColorRamp) At(v float64) color.RGBA64 {
+    rgba := func(c color.Color) [4]float64 {
+        r, g, b, a := c.RGBA()
+        return [4]float64{float64(r), float64(g), float64(b), float64(a)}
+    }
+    if len(ramp) == 0 {
+        return color.RGBA64{}
     }
+    if v <= ramp[0].At {
+        return color.RGBA64Model.Convert(ramp[0].Color).(color.RGBA64)
+    }
+    for i:=1; i < len(ramp); i++ {
+        if v > ramp[i].At {
+            continue
+        }
+        t := (v - ramp[i-1].At) / (ramp[i].At - ramp[i-1].At)
+        c0, c1 := rgba(ramp[i-1].Color), rgba(ramp[i].Color)
+        var c [4]uint16
+        for j := range c {
+            c[j] = uint16(math.Round(c0[j] + t*(c1[j]-c0[j])))
+        }
+        return color.RGBA64{c[0], c[1], c[2], c[3]}
+    }
+    return color.RGBA64Model.Convert(ramp[len(ramp)-1].Color).(color.RGBA64)
+}
+
+// NoiseInput configures the procedural textures below. they tile:
+// Scale lattice cells fit exactly across the texture, and each octave
+// doubles the number of cells
+type NoiseInput struct {
+    Width int
+    Height int
+    // number of noise cells across the texture, defaults to 4
+    Scale int
+    // layers of noise summed by fbm and its users, defaults to 1.
+    // octaves finer than both the 256 lattice cells and the pixels
+    // add no detail and are left out
+    Octaves int
+    // weight of each octave relative to the previous, defaults to 0.5
+    Persistence float64
+    // random source, as in GrayScottInput
+    Seed int64
+    Rand *rand.Rand
+    // colours of the values from 0 to 1, black to white if nil
+    Ramp ColorRamp
+}
+
+func (input NoiseInput) withDefaults() NoiseInput {
+    if input.Scale <= 0 {
+        input.Scale = 4
+    }
+    finest := 256
+    if input.Width > finest {
+        finest = input.Width
+    }
+    if input.Height > finest {
+        finest = input.Height
+    }
+    octaves, period := 1, input.Scale
+    for octaves < input.Octaves && period <= finest/2 {
+        octaves++
+        period *= 2
+    }
+    input.Octaves = octaves
+    if input.Persistence == 0 {
+        input.Persistence = 0.5
+    }
+    return input
+}
+
+func (input NoiseInput) rand() *rand.Rand {
+    if input.Rand != nil {
+        return input.Rand
+    }
+    return rand.New(rand.NewSource(input.Seed))
+}
+
+// noiseImage draws f, which gets coordinates from 0 to 1 over
+// the texture and returns values from 0 to 1
+func noiseImage(input NoiseInput, f func(x, y float64) float64) image.Image {
+    img := image.NewRGBA(image.Rect(0, 0, input.Width, input.Height))
+    for y:=0; y < input.Height; y++ {
+        for x:=0; x < input.Width; x++ {
+            v := f(float64(x)/float64(input.Width), float64(y)/float64(input.Height))
+            v = math.Max(0, math.Min(1, v))
+            i := 4*(y*input.Width+x)
+            if input.Ramp == nil {
+                bw := uint8(math.Round(v * 255))
+                img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = bw, bw, bw, 255
+                continue
+            }
+            c := input.Ramp.At(v)
+            img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(c.R>>8), uint8(c.G>>8), uint8(c.B>>8), uint8(c.A>>8)
+        }
+    }
+    return img
+}
+
+// perlin is gradient noise on a lattice that wraps around every period cells
+type perlin struct {
+    perm [256]int
+    grads [256][2]float64
+}
+
+func newPerlin(rnd *rand.Rand) *perlin {
+    p := &perlin{}
+    copy(p.perm[:], rnd.Perm(256))
+    for i := range p.grads {
+        angle := rnd.Float64() * 2 * math.Pi
+        p.grads[i] = [2]float64{math.Cos(angle), math.Sin(angle)}
+    }
+    return p
+}
+
+// at returns noise from about -1 to 1 at (x, y) in lattice cells
+func (p *perlin) at(x, y float64, period int) float64 {
+    x0, y0 := math.Floor(x), math.Floor(y)
+    fx, fy := x-x0, y-y0
+    xi, yi := wrap(int(x0), period), wrap(int(y0), period)
+    xn, yn := wrap(xi+1, period), wrap(yi+1, period)
+    dot := func(i, j int, dx, dy float64) float64 {
+        g := p.grads[p.perm[(p.perm[i&255]+j)&255]]
+        return g[0]*dx + g[1]*dy
+    }
+    fade := func(t float64) float64 {
+        return t * t * t * (t*(t*6-15) + 10)
+    }
+    u, v := fade(fx), fade(fy)
+    n0 := lerp(dot(xi, yi, fx, fy), dot(xn, yi, fx-1, fy), u)
+    n1 := lerp(dot(xi, yn, fx, fy-1), dot(xn, yn, fx-1, fy-1), u)
+    // gradient noise in 2d stays within sqrt(1/2)
+    return lerp(n0, n1, v) * math.Sqrt2
+}
+
+// fbm sums octaves of noise at (x, y) in texture coordinates,
+// returning values from about -1 to 1
+func (p *perlin) fbm(x, y float64, input NoiseInput) float64 {
+    return p.octaves(x, y, input, func(n float64) float64 { return n })
+}
+
+func (p *perlin) octaves(x, y float64, input NoiseInput, f func(float64) float64) float64 {
+    var sum, total float64
+    amplitude, period := 1.0, input.Scale
+    for o:=0; o < input.Octaves; o++ {
+        sum += amplitude * f(p.at(x*float64(period), y*float64(period), period))
+        total += amplitude
+        amplitude *= input.Persistence
+        period *= 2
+    }
+    return sum / total
+}
+
+func wrap(i, n int) int {
+    return ((i % n) + n) % n
+}
+
+func lerp(a, b, t float64) float64 {
+    return a + (b-a)*t
+}
+
+// PerlinNoise is a single octave of gradient noise
+func PerlinNoise(input NoiseInput) image.Image {
+    input = input.withDefaults()
+    input.Octaves = 1
+    return FBM(input)
+}
+
+// FBM is fractional brownian motion: octaves of gradient noise,
+// each at twice the frequency and Persistence times the amplitude
+func FBM(input NoiseInput) image.Image {
+    input = input.withDefaults()
+    p := newPerlin(input.rand())
+    return noiseImage(input, func(x, y float64) float64 {
+        return (p.fbm(x, y, input) + 1) / 2
+    })
+}
+
+// Turbulence is like FBM but sums the absolute value of each octave,
+// giving sharp creases where the noise crosses zero
+func Turbulence(input NoiseInput) image.Image {
+    input = input.withDefaults()
+    p := newPerlin(input.rand())
+    return noiseImage(input, func(x, y float64) float64 {
+        return p.octaves(x, y, input, math.Abs)
+    })
+}
+
+// Worley is cellular noise: the distance to the nearest of one random
+// point per cell, in cells. the nearest point can be up to two cells away,
+// so 5x5 cells are searched. Octaves and Persistence are not used
+func Worley(input NoiseInput) image.Image {
+    input = input.withDefaults()
+    rnd := input.rand()
+    n := input.Scale
+    points := make([][2]float64, n*n)
+    for i := range points {
+        points[i] = [2]float64{rnd.Float64(), rnd.Float64()}
+    }
+    return noiseImage(input, func(x, y float64) float64 {
+        cx, cy := x*float64(n), y*float64(n)
+        x0, y0 := int(math.Floor(cx)), int(math.Floor(cy))
+        min := math.Inf(1)
+        for dy:=-2; dy <= 2; dy++ {
+            for dx:=-2; dx <= 2; dx++ {
+                p := points[wrap(y0+dy, n)*n+wrap(x0+dx, n)]
+                px, py := float64(x0+dx)+p[0], float64(y0+dy)+p[1]
+                min = math.Min(min, math.Hypot(px-cx, py-cy))
+            }
+        }
+        return min
+    })
+}
+
+// Marble draws a number of vertical stripes, bent by turbulence
+// of the given strength
+func Marble(input NoiseInput, stripes int, strength float64) image.Image {
+    input = input.withDefaults()
+    p := newPerlin(input.rand())
+    return noiseImage(input, func(x, y float64) float64 {
+        t := p.octaves(x, y, input, math.Abs)
+        return (math.Sin(2*math.Pi*(float64(stripes)*x+strength*t)) + 1) / 2
+    })
+}
+
+// Wood is rings around the middle of the texture, rings per half its width,
+// disturbed by noise of the given strength. opposite edges are equally
+// far from the middle, so the rings join up with the neighbouring tiles
+func Wood(input NoiseInput, rings int, strength float64) image.Image {
+    input = input.withDefaults()
+    p := newPerlin(input.rand())
+    return noiseImage(input, func(x, y float64) float64 {
+        dx, dy := math.Abs(x-0.5), math.Abs(y-0.5)
+        d := math.Hypot(dx, dy) * 2 * float64(rings)
+        d += strength * p.fbm(x, y, input)
+        return d - math.Floor(d)
+    })
+}
+
+// DomainWarp is fbm looked up at a point moved by two more fbm fields,
+// amount texture widths at most, which swirls the pattern
+func DomainWarp(input NoiseInput, amount float64) image.Image {
+    input = input.withDefaults()
+    p := newPerlin(input.rand())
+    return noiseImage(input, func(x, y float64) float64 {
+        // offsets keep the three fields from being the same noise
+        wx := p.fbm(x+0.31, y+0.17, input)
+        wy := p.fbm(x+0.73, y+0.59, input)
+        return (p.fbm(x+amount*wx, y+amount*wy, input) + 1) / 2
+    })
 }
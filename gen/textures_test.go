@@ -0,0 +1,327 @@
+package gen
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+type coord struct {
+	X int
+	Y int
+}
+
+type ab struct {
+	A float64
+	B float64
+}
+
+// the original map based solver, kept as reference
+func referenceGrayScott(m map[coord]ab, w, h, iterations int, f, k, da, db float64) image.Image {
+	neighbours := func(x, y int) ([]ab, []ab) {
+		xn, xp, yn, yp := (x+1)%w, (x+w-1)%w, (y+1)%h, (y+h-1)%h
+		return []ab{m[coord{x, yp}], m[coord{x, yn}], m[coord{xp, y}], m[coord{xn, y}]},
+			[]ab{m[coord{xp, yp}], m[coord{xp, yn}], m[coord{xn, yp}], m[coord{xn, yn}]}
+	}
+	for n := 0; n < iterations; n++ {
+		newM := map[coord]ab{}
+		for c, cab := range m {
+			a, b := cab.A, cab.B
+			la := a * centerWeight
+			lb := b * centerWeight
+			adjacents, diagonals := neighbours(c.X, c.Y)
+			for _, n := range adjacents {
+				la += n.A * adjacentWeight
+				lb += n.B * adjacentWeight
+			}
+			for _, n := range diagonals {
+				la += n.A * diagonalWeight
+				lb += n.B * diagonalWeight
+			}
+			newa := a + ((da * la) - (a * b * b) + (f * (1.0 - a)))
+			newb := b + ((db * lb) + (a * b * b) - ((k + f) * b))
+			newM[c] = ab{A: newa, B: newb}
+		}
+		m = newM
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for c, ab := range m {
+		bw := uint8((1 - ab.B) * 255)
+		img.Set(c.X, c.Y, color.RGBA{bw, bw, bw, 255})
+	}
+	return img
+}
+
+func TestGrayScottMatchesReference(t *testing.T) {
+	w, h := 23, 17
+	input := GrayScottInput{
+		Width:      w,
+		Height:     h,
+		Iterations: 200,
+		FeedRate:   0.055,
+		KillRate:   0.062,
+		DiffRateA:  1.0,
+		DiffRateB:  0.5,
+	}
+	rnd := rand.New(rand.NewSource(1))
+	grid := newGrayScottGrid(w, h)
+	m := map[coord]ab{}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			b := 0.0
+			if rnd.Float64() < 0.1 {
+				b = 1.0
+			}
+			grid.a[y*w+x], grid.b[y*w+x] = 1.0, b
+			m[coord{x, y}] = ab{A: 1.0, B: b}
+		}
+	}
+	want := referenceGrayScott(m, w, h, input.Iterations, input.FeedRate, input.KillRate, input.DiffRateA, input.DiffRateB)
+	for _, workers := range []int{1, 4, 100} {
+		input.Workers = workers
+		start := newGrayScottGrid(w, h)
+		copy(start.a, grid.a)
+		copy(start.b, grid.b)
+		if got := start.run(input).result().Image(ChannelB, nil); !reflect.DeepEqual(got, want) {
+			t.Errorf("%d workers: output differs from reference", workers)
+		}
+	}
+}
+
+func TestGrayScottSeed(t *testing.T) {
+	input := GrayScottInput{
+		Width:      20,
+		Height:     20,
+		Iterations: 50,
+		FeedRate:   0.055,
+		KillRate:   0.062,
+		DiffRateA:  1.0,
+		DiffRateB:  0.5,
+		Seed:       42,
+	}
+	a, b := GrayScott(input), GrayScott(input)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("same seed gives different images")
+	}
+	input.Seed = 43
+	if reflect.DeepEqual(a, GrayScott(input)) {
+		t.Error("different seeds give the same image")
+	}
+	input.Rand = rand.New(rand.NewSource(42))
+	if !reflect.DeepEqual(a, GrayScott(input)) {
+		t.Error("injected source seeded the same gives a different image")
+	}
+}
+
+func TestGrayScottInitial(t *testing.T) {
+	mask := image.NewGray(image.Rect(0, 0, 2, 1))
+	mask.Pix[1] = 255
+	for i, tt := range []struct {
+		initial GrayScottInitial
+		// cells with B, from left to right and top to bottom
+		want string
+	}{
+		{CenterBlob(1), ".....\n..#..\n.###.\n..#..\n....."},
+		{Stripes(3, 1), "#..#.\n#..#.\n#..#.\n#..#.\n#..#."},
+		{Stripes(0, 1), "#####\n#####\n#####\n#####\n#####"},
+		{MaskSeeding(mask), "###..\n###..\n###..\n###..\n###.."},
+	} {
+		img := GrayScott(GrayScottInput{Width: 5, Height: 5, Initial: tt.initial})
+		got := ""
+		for y := 0; y < 5; y++ {
+			if y > 0 {
+				got += "\n"
+			}
+			for x := 0; x < 5; x++ {
+				if r, _, _, _ := img.At(x, y).RGBA(); r == 0 {
+					got += "#"
+				} else {
+					got += "."
+				}
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%d): got\n%s\nwant\n%s", i, got, tt.want)
+		}
+	}
+}
+
+func TestGrayScottFields(t *testing.T) {
+	input := GrayScottInput{
+		Width:      20,
+		Height:     10,
+		Iterations: 50,
+		FeedRate:   0.055,
+		KillRate:   0.062,
+		DiffRateA:  1.0,
+		DiffRateB:  0.5,
+		Seed:       1,
+	}
+	want := GrayScott(input)
+	constant := func(c float64) GrayScottField {
+		return func(x, y float64) float64 { return c }
+	}
+	white := image.NewGray(image.Rect(0, 0, 3, 3))
+	for i := range white.Pix {
+		white.Pix[i] = 255
+	}
+	fields := input
+	fields.FeedField = constant(0.055)
+	fields.KillField = ImageField(white, 0, 0.062)
+	fields.DiffAField = LinearField(1.0, 1.0, false)
+	fields.DiffBField = constant(0.5)
+	if got := GrayScott(fields); !reflect.DeepEqual(got, want) {
+		t.Error("constant fields differ from scalar rates")
+	}
+	fields.KillField = LinearField(0.045, 0.07, true)
+	if got := GrayScott(fields); reflect.DeepEqual(got, want) {
+		t.Error("varying kill rate has no effect")
+	}
+}
+
+func TestImageField(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 1))
+	img.Pix[1] = 255
+	f := ImageField(img, 0.01, 0.03)
+	if got := f(0.25, 0.5); got != 0.01 {
+		t.Errorf("got %f want 0.01 on black", got)
+	}
+	if got := f(0.75, 0.5); got != 0.03 {
+		t.Errorf("got %f want 0.03 on white", got)
+	}
+}
+
+func TestColorRamp(t *testing.T) {
+	ramp := ColorRamp{
+		{At: 0.2, Color: color.RGBA{0, 0, 0, 255}},
+		{At: 0.6, Color: color.RGBA{200, 100, 0, 255}},
+	}
+	for _, tt := range []struct {
+		v    float64
+		want color.RGBA
+	}{
+		{0, color.RGBA{0, 0, 0, 255}},
+		{0.4, color.RGBA{100, 50, 0, 255}},
+		{0.6, color.RGBA{200, 100, 0, 255}},
+		{1, color.RGBA{200, 100, 0, 255}},
+	} {
+		if got := color.RGBAModel.Convert(ramp.At(tt.v)); got != tt.want {
+			t.Errorf("%f: got %v want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestGrayScottResultOutputs(t *testing.T) {
+	r := GrayScottResult{Width: 2, Height: 2, A: []float64{1, 0.5, 0.5, 1}, B: []float64{0, 0.5, 0.25, 0}}
+	if got, want := r.Values(ChannelAB), []float64{1, 0.5, 0.625, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("A-B: got %v want %v", got, want)
+	}
+	if got := r.Image(ChannelB, nil).At(1, 0); got != (color.RGBA{127, 127, 127, 255}) {
+		t.Errorf("grey: got %v", got)
+	}
+	ramp := ColorRamp{{At: 0, Color: color.Black}, {At: 1, Color: color.RGBA{0, 0, 255, 255}}}
+	if got := r.Image(ChannelA, ramp).At(0, 0); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("ramp: got %v", got)
+	}
+	if got := r.Gray16(ChannelB).Gray16At(0, 1).Y; got != 0x4000 {
+		t.Errorf("16 bit: got %#x want 0x4000", got)
+	}
+	height := r.HeightMap(ChannelB)
+	if lo, hi := height.Gray16At(0, 0).Y, height.Gray16At(1, 0).Y; lo != 0 || hi != 0xffff {
+		t.Errorf("height map: got %#x to %#x want full range", lo, hi)
+	}
+}
+
+func TestGrayScottNormalMap(t *testing.T) {
+	// B rises along x, except where it wraps around
+	r := GrayScottResult{Width: 4, Height: 2, A: make([]float64, 8), B: []float64{0, 1, 2, 3, 0, 1, 2, 3}}
+	img := r.NormalMap(ChannelB, 1).(*image.RGBA)
+	if got := img.RGBAAt(1, 0); got.R >= 128 || got.G != 128 || got.B <= 128 {
+		t.Errorf("slope: got %v want a normal tilted towards -x", got)
+	}
+	flat := GrayScottResult{Width: 2, Height: 2, A: make([]float64, 4), B: make([]float64, 4)}
+	if got := flat.NormalMap(ChannelB, 1).At(0, 0); got != (color.RGBA{128, 128, 255, 255}) {
+		t.Errorf("flat: got %v want straight up", got)
+	}
+}
+
+func TestPerlinTiles(t *testing.T) {
+	p := newPerlin(rand.New(rand.NewSource(3)))
+	input := NoiseInput{Scale: 3, Octaves: 4}.withDefaults()
+	for i := 0; i < 10; i++ {
+		v := float64(i) / 10
+		if a, b := p.fbm(0, v, input), p.fbm(1, v, input); math.Abs(a-b) > 1e-9 {
+			t.Errorf("x edges differ at y=%f: %f %f", v, a, b)
+		}
+		if a, b := p.fbm(v, 0, input), p.fbm(v, 1, input); math.Abs(a-b) > 1e-9 {
+			t.Errorf("y edges differ at x=%f: %f %f", v, a, b)
+		}
+		if n := p.at(v*3, 1.3, 3); n < -1 || n > 1 {
+			t.Errorf("noise out of range: %f", n)
+		}
+	}
+}
+
+func TestNoiseTextures(t *testing.T) {
+	generators := map[string]func(NoiseInput) image.Image{
+		"perlin":     PerlinNoise,
+		"fbm":        FBM,
+		"turbulence": Turbulence,
+		"worley":     Worley,
+		"marble":     func(in NoiseInput) image.Image { return Marble(in, 3, 2) },
+		"wood":       func(in NoiseInput) image.Image { return Wood(in, 6, 0.3) },
+		"warp":       func(in NoiseInput) image.Image { return DomainWarp(in, 0.3) },
+	}
+	for name, f := range generators {
+		input := NoiseInput{Width: 32, Height: 16, Octaves: 3, Seed: 1}
+		a, b := f(input), f(input)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s: same seed gives different images", name)
+		}
+		if a.Bounds() != image.Rect(0, 0, 32, 16) {
+			t.Errorf("%s: got bounds %v", name, a.Bounds())
+		}
+		input.Seed = 2
+		if reflect.DeepEqual(a, f(input)) {
+			t.Errorf("%s: seed has no effect", name)
+		}
+		// the rightmost column continues into the leftmost one
+		var step, jump float64
+		for y := 0; y < 16; y++ {
+			for x := 1; x < 32; x++ {
+				step = math.Max(step, grayDiff(a.At(x-1, y), a.At(x, y)))
+			}
+			jump = math.Max(jump, grayDiff(a.At(31, y), a.At(0, y)))
+		}
+		if jump > step {
+			t.Errorf("%s: seam of %f where neighbouring pixels differ at most %f", name, jump, step)
+		}
+	}
+}
+
+func TestNoiseOctavesClamped(t *testing.T) {
+	for _, tt := range []struct {
+		input NoiseInput
+		want  int
+	}{
+		{NoiseInput{Octaves: 63}, 7},
+		{NoiseInput{Scale: 4, Width: 1024, Octaves: 63}, 9},
+		{NoiseInput{Scale: math.MaxInt32, Octaves: 3}, 1},
+		{NoiseInput{Octaves: -1}, 1},
+	} {
+		if got := tt.input.withDefaults().Octaves; got != tt.want {
+			t.Errorf("%+v: got %d octaves want %d", tt.input, got, tt.want)
+		}
+	}
+	// used to panic dividing by a period that overflowed to 0
+	FBM(NoiseInput{Width: 4, Height: 4, Octaves: 63})
+}
+
+func grayDiff(a, b color.Color) float64 {
+	ga := color.GrayModel.Convert(a).(color.Gray).Y
+	gb := color.GrayModel.Convert(b).(color.Gray).Y
+	return math.Abs(float64(ga) - float64(gb))
+}
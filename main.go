@@ -1,8 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"math"
+	"os"
 
     //"image/png"
     //"os"
@@ -10,13 +12,20 @@ import (
 	m "github.com/deosjr/GRayT/src/model"
 	"github.com/deosjr/GRayT/src/render"
     "github.com/deosjr/GenGeo/gen"
+    "github.com/deosjr/GenGeo/meshio"
+    scenefile "github.com/deosjr/GenGeo/scene"
 )
 
 var (
-	width      uint = 1600
-	height     uint = 1200
-	numWorkers      = 10
-	numSamples      = 10
+	width        = flag.Uint("width", 1600, "width of the image in pixels")
+	height       = flag.Uint("height", 1200, "height of the image in pixels")
+	numWorkers   = flag.Int("workers", 10, "number of goroutines rendering")
+	numSamples   = flag.Int("samples", 10, "number of samples per pixel")
+	tracer       = flag.String("tracer", "whitted", "tracer type: whitted, or the number of a GRayT tracer type")
+	output       = flag.String("o", "out.png", "output png file")
+	tessellation = flag.Int("tessellation", 32, "triangles per patch side, for tessellation*tessellation*2 triangles per patch")
+	patchFile    = flag.String("patches", "teapot", "bezier patch file to render")
+	dryRun       = flag.Bool("dry-run", false, "print scene statistics without rendering")
 
 	ex = m.Vector{1, 0, 0}
 	ey = m.Vector{0, 1, 0}
@@ -24,8 +33,41 @@ var (
 )
 
 func main() {
+	flag.Parse()
+	tracerType, err := scenefile.ParseTracer(*tracer)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *tessellation < 1 {
+		fmt.Fprintln(os.Stderr, "tessellation should be at least 1")
+		os.Exit(2)
+	}
+	if *numWorkers < 1 {
+		fmt.Fprintln(os.Stderr, "workers should be at least 1")
+		os.Exit(2)
+	}
+	if *numSamples < 1 {
+		fmt.Fprintln(os.Stderr, "samples should be at least 1")
+		os.Exit(2)
+	}
+
+	patches, err := meshio.LoadPatches(*patchFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *dryRun {
+		triangles := len(patches) * *tessellation * *tessellation * 2
+		fmt.Printf("patches: %d from %s\n", len(patches), *patchFile)
+		fmt.Printf("triangles: %d (%d per patch)\n", triangles, *tessellation * *tessellation * 2)
+		fmt.Printf("image: %dx%d, %d samples per pixel, %d workers, tracer %s\n", *width, *height, *numSamples, *numWorkers, *tracer)
+		fmt.Printf("output: %s\n", *output)
+		return
+	}
+
 	fmt.Println("Creating scene...")
-	camera := m.NewPerspectiveCamera(width, height, 0.5*math.Pi)
+	camera := m.NewPerspectiveCamera(*width, *height, 0.5*math.Pi)
 	scene := m.NewScene(camera)
 
 	l1 := m.NewDistantLight(m.Vector{1, -1, 1}, m.NewColor(255, 255, 255), 50)
@@ -65,11 +107,6 @@ func main() {
     texture := m.NewImageTexture(img, m.TriangleMeshUVFunc)
 	diffMat := m.NewDiffuseMaterial(texture)
 
-    patches, err := LoadPatches("teapot")
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
 	translation := m.Translate(m.Vector{0, -2, 2})
 	rotation := m.RotateX(-math.Pi/2.0).Mul(m.RotateX(math.Pi/8.0))
 	// NOTE: enable scale to render the original teapot
@@ -77,7 +114,7 @@ func main() {
 	transformation := translation.Mul(rotation)//.Mul(scale)
 	for _, patch := range patches {
 		//diffMat := &m.DiffuseMaterial{Color: m.NewColor(uint8(rand.Intn(255)), uint8(rand.Intn(255)), uint8(rand.Intn(255)))}
-		complexObject := patch.TriangulateWithNormalMapping(32, diffMat)
+		complexObject := patch.TriangulateWithNormalMapping(*tessellation, diffMat)
 		// not really a mesh but I guess thats WIP
 		patchMesh := m.NewSharedObject(complexObject, transformation)
 		scene.Add(patchMesh)
@@ -89,13 +126,13 @@ func main() {
 	camera.LookAt(from, to, ey)
 	params := render.Params{
 		Scene:        scene,
-		NumWorkers:   numWorkers,
-		NumSamples:   numSamples,
+		NumWorkers:   *numWorkers,
+		NumSamples:   *numSamples,
 		AntiAliasing: true,
-		TracerType:   m.WhittedStyle,
+		TracerType:   tracerType,
 	}
 	fmt.Println("Rendering...")
 	film := render.Render(params)
-	film.SaveAsPNG("out.png")
+	film.SaveAsPNG(*output)
 }
 
@@ -0,0 +1,441 @@
+package meshio
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+// GLTFScene is a scene graph to be written as gltf 2.0.
+// nodes refer to meshes and meshes to materials by index,
+// so one mesh can be instanced by several nodes with their own transform,
+// like m.NewSharedObject does for renderable objects.
+// NOTE: coordinates are written unchanged; gltf viewers assume
+// a right-handed system with y up
+type GLTFScene struct {
+	Materials []GLTFMaterial
+	Meshes    []GLTFMesh
+	Nodes     []GLTFNode
+}
+
+// GLTFMaterial is a diffuse material: base colour multiplied by an
+// optional texture. a nil BaseColor is white
+type GLTFMaterial struct {
+	Name        string
+	BaseColor   color.Color
+	Texture     image.Image
+	DoubleSided bool
+}
+
+// GLTFMesh uses material with index Material, or the default material if -1.
+// normals and uvs are written if the mesh has them, for example
+// when it comes from gen.GridMesh, which samples a surface on the same grid
+// as TriangulateWithNormalMapping
+type GLTFMesh struct {
+	Name     string
+	Mesh     gen.Mesh
+	Material int
+}
+
+// GLTFNode instances mesh with index Mesh, or no mesh if nil.
+// Transform is relative to the parent node; nil means identity.
+// nodes that are nobody's child are the roots of the scene;
+// a node is the child of at most one node and never its own descendant
+type GLTFNode struct {
+	Name      string
+	Mesh      *int
+	Transform *m.Transform
+	Children  []int
+}
+
+func (s *GLTFScene) AddMaterial(mat GLTFMaterial) int {
+	s.Materials = append(s.Materials, mat)
+	return len(s.Materials) - 1
+}
+
+func (s *GLTFScene) AddMesh(name string, mesh gen.Mesh, material int) int {
+	s.Meshes = append(s.Meshes, GLTFMesh{Name: name, Mesh: mesh, Material: material})
+	return len(s.Meshes) - 1
+}
+
+func (s *GLTFScene) AddNode(node GLTFNode) int {
+	s.Nodes = append(s.Nodes, node)
+	return len(s.Nodes) - 1
+}
+
+// SaveGLTF writes the scene to filename, as binary glb
+// if the filename ends in .glb and as gltf json otherwise
+func SaveGLTF(filename string, scene GLTFScene) (err error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer closeFile(file, &err)
+	if strings.EqualFold(filepath.Ext(filename), ".glb") {
+		return WriteGLB(file, scene)
+	}
+	return WriteGLTF(file, scene)
+}
+
+// WriteGLTF writes the scene as gltf json, with all geometry
+// and textures embedded as a base64 data uri
+func WriteGLTF(w io.Writer, scene GLTFScene) error {
+	doc, bin, err := buildGLTF(scene)
+	if err != nil {
+		return err
+	}
+	if len(doc.Buffers) > 0 {
+		doc.Buffers[0].URI = "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(bin)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// glb container: 12 byte header followed by a json and a binary chunk,
+// both padded to 4 bytes. all little endian
+const (
+	glbMagic     = 0x46546C67
+	glbChunkJSON = 0x4E4F534A
+	glbChunkBIN  = 0x004E4942
+)
+
+// WriteGLB writes the scene as a single binary glb file
+func WriteGLB(w io.Writer, scene GLTFScene) error {
+	doc, bin, err := buildGLTF(scene)
+	if err != nil {
+		return err
+	}
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	for len(js)%4 != 0 {
+		js = append(js, ' ')
+	}
+	for len(bin)%4 != 0 {
+		bin = append(bin, 0)
+	}
+	length := 12 + 8 + len(js)
+	if len(bin) > 0 {
+		length += 8 + len(bin)
+	}
+	if uint64(length) > math.MaxUint32 {
+		return fmt.Errorf("Scene too large for glb: %d bytes", length)
+	}
+	header := []uint32{glbMagic, 2, uint32(length), uint32(len(js)), glbChunkJSON}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if _, err := w.Write(js); err != nil {
+		return err
+	}
+	// the binary chunk is optional, and left out when empty
+	if len(bin) == 0 {
+		return nil
+	}
+	if err := binary.Write(w, binary.LittleEndian, []uint32{uint32(len(bin)), glbChunkBIN}); err != nil {
+		return err
+	}
+	_, err = w.Write(bin)
+	return err
+}
+
+// the subset of the gltf 2.0 json schema written here.
+// optional indices are pointers so index 0 is not omitted
+type gltfDocument struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfSceneNodes `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes,omitempty"`
+	Meshes      []gltfMesh       `json:"meshes,omitempty"`
+	Materials   []gltfMaterial   `json:"materials,omitempty"`
+	Textures    []gltfTexture    `json:"textures,omitempty"`
+	Images      []gltfImage      `json:"images,omitempty"`
+	Accessors   []gltfAccessor   `json:"accessors,omitempty"`
+	BufferViews []gltfBufferView `json:"bufferViews,omitempty"`
+	Buffers     []gltfBuffer     `json:"buffers,omitempty"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type gltfSceneNodes struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name     string    `json:"name,omitempty"`
+	Mesh     *int      `json:"mesh,omitempty"`
+	Matrix   []float32 `json:"matrix,omitempty"`
+	Children []int     `json:"children,omitempty"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name,omitempty"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   *int           `json:"material,omitempty"`
+}
+
+type gltfMaterial struct {
+	Name                 string  `json:"name,omitempty"`
+	PBRMetallicRoughness gltfPBR `json:"pbrMetallicRoughness"`
+	DoubleSided          bool    `json:"doubleSided,omitempty"`
+}
+
+type gltfPBR struct {
+	BaseColorFactor  [4]float32       `json:"baseColorFactor"`
+	BaseColorTexture *gltfTextureInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor   float32          `json:"metallicFactor"`
+	RoughnessFactor  float32          `json:"roughnessFactor"`
+}
+
+type gltfTextureInfo struct {
+	Index int `json:"index"`
+}
+
+type gltfTexture struct {
+	Source int `json:"source"`
+}
+
+type gltfImage struct {
+	BufferView int    `json:"bufferView"`
+	MimeType   string `json:"mimeType"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	URI        string `json:"uri,omitempty"`
+}
+
+const (
+	gltfFloat        = 5126
+	gltfUnsignedInt  = 5125
+	gltfArrayBuffer  = 34962
+	gltfElementArray = 34963
+)
+
+// gltfBuilder collects the json document and the single binary buffer
+type gltfBuilder struct {
+	doc gltfDocument
+	bin bytes.Buffer
+}
+
+func buildGLTF(scene GLTFScene) (gltfDocument, []byte, error) {
+	b := &gltfBuilder{doc: gltfDocument{
+		Asset:  gltfAsset{Version: "2.0", Generator: "GenGeo"},
+		Scenes: []gltfSceneNodes{{Nodes: []int{}}},
+	}}
+	for _, mat := range scene.Materials {
+		if err := b.addMaterial(mat); err != nil {
+			return gltfDocument{}, nil, err
+		}
+	}
+	for i, mesh := range scene.Meshes {
+		if mesh.Material >= len(scene.Materials) {
+			return gltfDocument{}, nil, fmt.Errorf("Mesh %d: material not found: %d", i, mesh.Material)
+		}
+		if err := b.addMesh(mesh); err != nil {
+			return gltfDocument{}, nil, fmt.Errorf("Mesh %d: %s", i, err)
+		}
+	}
+	isChild := make([]bool, len(scene.Nodes))
+	for i, node := range scene.Nodes {
+		n := gltfNode{Name: node.Name, Children: node.Children}
+		if node.Mesh != nil {
+			mesh := *node.Mesh
+			if mesh < 0 || mesh >= len(scene.Meshes) {
+				return gltfDocument{}, nil, fmt.Errorf("Node %d: mesh not found: %d", i, mesh)
+			}
+			n.Mesh = &mesh
+		}
+		if node.Transform != nil {
+			n.Matrix = transformMatrix(*node.Transform)
+		}
+		for _, c := range node.Children {
+			if c < 0 || c >= len(scene.Nodes) || isChild[c] {
+				return gltfDocument{}, nil, fmt.Errorf("Node %d: invalid child: %d", i, c)
+			}
+			isChild[c] = true
+		}
+		b.doc.Nodes = append(b.doc.Nodes, n)
+	}
+	for i, child := range isChild {
+		if !child {
+			b.doc.Scenes[0].Nodes = append(b.doc.Scenes[0].Nodes, i)
+		}
+	}
+	// with at most one parent per node, nodes that cannot be
+	// reached from the roots are part of a cycle
+	reached := make([]bool, len(scene.Nodes))
+	stack := append([]int{}, b.doc.Scenes[0].Nodes...)
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		reached[i] = true
+		stack = append(stack, scene.Nodes[i].Children...)
+	}
+	for i, r := range reached {
+		if !r {
+			return gltfDocument{}, nil, fmt.Errorf("Node %d: part of a cycle", i)
+		}
+	}
+	// a buffer cannot be empty, so a scene without geometry has none
+	if b.bin.Len() > 0 {
+		b.doc.Buffers = []gltfBuffer{{ByteLength: b.bin.Len()}}
+	}
+	return b.doc, b.bin.Bytes(), nil
+}
+
+// transformMatrix reads the matrix of a transform by applying it
+// to the basis vectors and origin; gltf stores it column-major
+func transformMatrix(t m.Transform) []float32 {
+	x, y, z := t.Vector(m.Vector{1, 0, 0}), t.Vector(m.Vector{0, 1, 0}), t.Vector(m.Vector{0, 0, 1})
+	o := t.Point(m.Vector{})
+	return []float32{
+		x.X, x.Y, x.Z, 0,
+		y.X, y.Y, y.Z, 0,
+		z.X, z.Y, z.Z, 0,
+		o.X, o.Y, o.Z, 1,
+	}
+}
+
+func (b *gltfBuilder) addMaterial(mat GLTFMaterial) error {
+	factor := [4]float32{1, 1, 1, 1}
+	if mat.BaseColor != nil {
+		r, g, bl, a := mat.BaseColor.RGBA()
+		factor = [4]float32{float32(r) / 0xffff, float32(g) / 0xffff, float32(bl) / 0xffff, float32(a) / 0xffff}
+	}
+	material := gltfMaterial{
+		Name:        mat.Name,
+		DoubleSided: mat.DoubleSided,
+		PBRMetallicRoughness: gltfPBR{
+			BaseColorFactor: factor,
+			RoughnessFactor: 1,
+		},
+	}
+	if mat.Texture != nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, mat.Texture); err != nil {
+			return err
+		}
+		view := b.addBufferView(buf.Bytes(), 0)
+		b.doc.Images = append(b.doc.Images, gltfImage{BufferView: view, MimeType: "image/png"})
+		b.doc.Textures = append(b.doc.Textures, gltfTexture{Source: len(b.doc.Images) - 1})
+		material.PBRMetallicRoughness.BaseColorTexture = &gltfTextureInfo{Index: len(b.doc.Textures) - 1}
+	}
+	b.doc.Materials = append(b.doc.Materials, material)
+	return nil
+}
+
+// NOTE: uvs are written unchanged; gltf puts (0,0) at the top left of the image
+func (b *gltfBuilder) addMesh(mesh GLTFMesh) error {
+	gm := mesh.Mesh
+	if len(gm.Faces) == 0 {
+		return fmt.Errorf("Empty mesh")
+	}
+	attributes := map[string]int{}
+	min, max := gm.Bounds()
+	attributes["POSITION"] = b.addVectors(gm.Vertices, 3, []float32{min.X, min.Y, min.Z}, []float32{max.X, max.Y, max.Z})
+	if len(gm.Normals) == len(gm.Vertices) {
+		attributes["NORMAL"] = b.addVectors(gm.Normals, 3, nil, nil)
+	}
+	if len(gm.UVs) == len(gm.Vertices) {
+		attributes["TEXCOORD_0"] = b.addVectors(gm.UVs, 2, nil, nil)
+	}
+
+	data := make([]byte, 12*len(gm.Faces))
+	for i, f := range gm.Faces {
+		for j, index := range f {
+			if index < 0 || index >= len(gm.Vertices) {
+				return fmt.Errorf("Face %d: vertex not found: %d", i, index)
+			}
+			binary.LittleEndian.PutUint32(data[12*i+4*j:], uint32(index))
+		}
+	}
+	view := b.addBufferView(data, gltfElementArray)
+	b.doc.Accessors = append(b.doc.Accessors, gltfAccessor{
+		BufferView:    view,
+		ComponentType: gltfUnsignedInt,
+		Count:         3 * len(gm.Faces),
+		Type:          "SCALAR",
+	})
+	primitive := gltfPrimitive{Attributes: attributes, Indices: len(b.doc.Accessors) - 1}
+	if mesh.Material >= 0 {
+		material := mesh.Material
+		primitive.Material = &material
+	}
+	b.doc.Meshes = append(b.doc.Meshes, gltfMesh{Name: mesh.Name, Primitives: []gltfPrimitive{primitive}})
+	return nil
+}
+
+// addVectors stores the first n components of each vector as floats
+// and returns the index of the accessor
+func (b *gltfBuilder) addVectors(vectors []m.Vector, n int, min, max []float32) int {
+	data := make([]byte, 4*n*len(vectors))
+	for i, v := range vectors {
+		for j, f := range []float32{v.X, v.Y, v.Z}[:n] {
+			binary.LittleEndian.PutUint32(data[4*(n*i+j):], math.Float32bits(f))
+		}
+	}
+	view := b.addBufferView(data, gltfArrayBuffer)
+	b.doc.Accessors = append(b.doc.Accessors, gltfAccessor{
+		BufferView:    view,
+		ComponentType: gltfFloat,
+		Count:         len(vectors),
+		Type:          fmt.Sprintf("VEC%d", n),
+		Min:           min,
+		Max:           max,
+	})
+	return len(b.doc.Accessors) - 1
+}
+
+// addBufferView appends data to the buffer, aligned to 4 bytes
+func (b *gltfBuilder) addBufferView(data []byte, target int) int {
+	for b.bin.Len()%4 != 0 {
+		b.bin.WriteByte(0)
+	}
+	b.doc.BufferViews = append(b.doc.BufferViews, gltfBufferView{
+		ByteOffset: b.bin.Len(),
+		ByteLength: len(data),
+		Target:     target,
+	})
+	b.bin.Write(data)
+	return len(b.doc.BufferViews) - 1
+}
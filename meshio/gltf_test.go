@@ -0,0 +1,167 @@
+package meshio
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+func testGLTFScene() GLTFScene {
+	square := gen.Mesh{
+		Vertices: []m.Vector{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+		Normals:  []m.Vector{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+		UVs:      []m.Vector{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+		Faces:    [][3]int{{0, 1, 2}, {0, 2, 3}},
+	}
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	scene := GLTFScene{}
+	mat := scene.AddMaterial(GLTFMaterial{Name: "texture", BaseColor: color.White, Texture: img})
+	mesh := scene.AddMesh("square", square, mat)
+	t := m.Translate(m.Vector{1, 2, 3})
+	a := scene.AddNode(GLTFNode{Name: "a", Mesh: &mesh})
+	b := scene.AddNode(GLTFNode{Name: "b", Mesh: &mesh, Transform: &t})
+	scene.AddNode(GLTFNode{Name: "root", Children: []int{a, b}})
+	return scene
+}
+
+func checkGLTFDocument(t *testing.T, doc gltfDocument) {
+	if doc.Asset.Version != "2.0" {
+		t.Errorf("got version %q", doc.Asset.Version)
+	}
+	if len(doc.Scenes) != 1 || len(doc.Scenes[0].Nodes) != 1 || doc.Scenes[0].Nodes[0] != 2 {
+		t.Errorf("got scenes %v want root node 2", doc.Scenes)
+	}
+	if len(doc.Meshes) != 1 || len(doc.Nodes) != 3 {
+		t.Fatalf("got %d meshes and %d nodes", len(doc.Meshes), len(doc.Nodes))
+	}
+	if doc.Nodes[0].Mesh == nil || doc.Nodes[1].Mesh == nil || *doc.Nodes[0].Mesh != 0 || *doc.Nodes[1].Mesh != 0 {
+		t.Errorf("mesh not shared between nodes: %v", doc.Nodes)
+	}
+	if doc.Nodes[0].Matrix != nil {
+		t.Errorf("identity written as matrix %v", doc.Nodes[0].Matrix)
+	}
+	want := []float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 1, 2, 3, 1}
+	for i, f := range doc.Nodes[1].Matrix {
+		if f != want[i] {
+			t.Errorf("got matrix %v want %v", doc.Nodes[1].Matrix, want)
+			break
+		}
+	}
+	p := doc.Meshes[0].Primitives[0]
+	for _, attr := range []string{"POSITION", "NORMAL", "TEXCOORD_0"} {
+		if _, ok := p.Attributes[attr]; !ok {
+			t.Errorf("missing attribute %s", attr)
+		}
+	}
+	if doc.Accessors[p.Indices].Count != 6 {
+		t.Errorf("got %d indices want 6", doc.Accessors[p.Indices].Count)
+	}
+	if pos := doc.Accessors[p.Attributes["POSITION"]]; pos.Count != 4 || pos.Max[0] != 1 || pos.Min[0] != 0 {
+		t.Errorf("got position accessor %v", pos)
+	}
+	if len(doc.Images) != 1 || doc.Materials[0].PBRMetallicRoughness.BaseColorTexture == nil {
+		t.Errorf("texture missing")
+	}
+}
+
+func TestWriteGLB(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGLB(&buf, testGLTFScene()); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if binary.LittleEndian.Uint32(b) != glbMagic || binary.LittleEndian.Uint32(b[4:]) != 2 {
+		t.Fatalf("invalid header %v", b[:8])
+	}
+	if n := binary.LittleEndian.Uint32(b[8:]); int(n) != len(b) || n%4 != 0 {
+		t.Errorf("got length %d for %d bytes", n, len(b))
+	}
+	jsonLength := binary.LittleEndian.Uint32(b[12:])
+	var doc gltfDocument
+	if err := json.Unmarshal(b[20:20+jsonLength], &doc); err != nil {
+		t.Fatal(err)
+	}
+	checkGLTFDocument(t, doc)
+	binLength := binary.LittleEndian.Uint32(b[20+jsonLength:])
+	if int(binLength) < doc.Buffers[0].ByteLength || doc.Buffers[0].URI != "" {
+		t.Errorf("got buffer %v for chunk of %d bytes", doc.Buffers[0], binLength)
+	}
+}
+
+func TestWriteGLTF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGLTF(&buf, testGLTFScene()); err != nil {
+		t.Fatal(err)
+	}
+	var doc gltfDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	checkGLTFDocument(t, doc)
+	prefix := "data:application/octet-stream;base64,"
+	if !strings.HasPrefix(doc.Buffers[0].URI, prefix) {
+		t.Fatalf("buffer not embedded")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(doc.Buffers[0].URI, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != doc.Buffers[0].ByteLength {
+		t.Errorf("got %d bytes want %d", len(data), doc.Buffers[0].ByteLength)
+	}
+}
+
+func TestWriteGLTFInvalid(t *testing.T) {
+	scene := testGLTFScene()
+	missing := 5
+	scene.Nodes[0].Mesh = &missing
+	if err := WriteGLTF(&bytes.Buffer{}, scene); err == nil {
+		t.Error("expected error for missing mesh")
+	}
+	scene = testGLTFScene()
+	scene.Nodes[2].Children = []int{0, 0}
+	if err := WriteGLTF(&bytes.Buffer{}, scene); err == nil {
+		t.Error("expected error for node with two parents")
+	}
+	scene = testGLTFScene()
+	scene.Nodes[0].Children = []int{2}
+	if err := WriteGLTF(&bytes.Buffer{}, scene); err == nil {
+		t.Error("expected error for cycle")
+	}
+	scene = testGLTFScene()
+	scene.Nodes[1].Children = []int{1}
+	if err := WriteGLTF(&bytes.Buffer{}, scene); err == nil {
+		t.Error("expected error for node that is its own child")
+	}
+}
+
+func TestWriteGLTFWithoutGeometry(t *testing.T) {
+	scene := GLTFScene{}
+	scene.AddNode(GLTFNode{Name: "empty"})
+	var buf bytes.Buffer
+	if err := WriteGLTF(&buf, scene); err != nil {
+		t.Fatal(err)
+	}
+	var doc gltfDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Buffers) != 0 || doc.Nodes[0].Mesh != nil {
+		t.Errorf("got buffers %v and mesh %v", doc.Buffers, doc.Nodes[0].Mesh)
+	}
+	buf.Reset()
+	if err := WriteGLB(&buf, scene); err != nil {
+		t.Fatal(err)
+	}
+	if n := binary.LittleEndian.Uint32(buf.Bytes()[8:]); int(n) != buf.Len() {
+		t.Errorf("got length %d for %d bytes", n, buf.Len())
+	}
+}
@@ -0,0 +1,414 @@
+package meshio
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+// OBJObject is a named mesh in a wavefront obj file,
+// using the material with name Material from the accompanying mtl file
+type OBJObject struct {
+	Name     string
+	Mesh     gen.Mesh
+	Material string
+}
+
+// OBJMaterial is a material in a wavefront mtl file.
+// Texture is written as a png next to the mtl file if set,
+// using TextureFile as its filename
+type OBJMaterial struct {
+	Name    string
+	Diffuse color.Color
+	Texture image.Image
+	// defaults to the name of the material with .png,
+	// or texture and its index if the material has no name
+	TextureFile string
+}
+
+func (mat OBJMaterial) textureFile(i int) string {
+	switch {
+	case mat.TextureFile != "":
+		return mat.TextureFile
+	case mat.Name != "":
+		return mat.Name + ".png"
+	}
+	return fmt.Sprintf("texture%d.png", i)
+}
+
+// SaveOBJ writes objects to filename and, if there are any materials,
+// an mtl file and textures next to it
+func SaveOBJ(filename string, objects []OBJObject, materials []OBJMaterial) (err error) {
+	mtlFile := ""
+	if len(materials) > 0 {
+		mtlFile = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".mtl"
+		if err := saveMTL(mtlFile, materials); err != nil {
+			return err
+		}
+		mtlFile = filepath.Base(mtlFile)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer closeFile(file, &err)
+	return WriteOBJ(file, mtlFile, objects)
+}
+
+func saveMTL(filename string, materials []OBJMaterial) (err error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer closeFile(file, &err)
+	if err := WriteMTL(file, materials); err != nil {
+		return err
+	}
+	dir := filepath.Dir(filename)
+	for i, mat := range materials {
+		if mat.Texture == nil {
+			continue
+		}
+		if err := savePNG(filepath.Join(dir, mat.textureFile(i)), mat.Texture); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func savePNG(filename string, img image.Image) (err error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer closeFile(file, &err)
+	return png.Encode(file, img)
+}
+
+// closeFile closes a written file, reporting a failure to flush it
+// unless writing already failed
+func closeFile(file *os.File, err *error) {
+	if cerr := file.Close(); *err == nil {
+		*err = cerr
+	}
+}
+
+// WriteOBJ writes objects in wavefront obj format.
+// positions, normals and uvs are shared between faces and objects
+// wherever they are equal; indices in the file are 1-based
+func WriteOBJ(w io.Writer, mtlFile string, objects []OBJObject) error {
+	bw := bufio.NewWriter(w)
+	if mtlFile != "" {
+		fmt.Fprintf(bw, "mtllib %s\n", mtlFile)
+	}
+	positions := newVectorIndex(bw, "v")
+	normals := newVectorIndex(bw, "vn")
+	uvs := newVectorIndex(bw, "vt")
+	for _, o := range objects {
+		if o.Name != "" {
+			fmt.Fprintf(bw, "o %s\n", o.Name)
+		}
+		if o.Material != "" {
+			fmt.Fprintf(bw, "usemtl %s\n", o.Material)
+		}
+		mesh := o.Mesh
+		hasNormals := len(mesh.Normals) == len(mesh.Vertices)
+		hasUVs := len(mesh.UVs) == len(mesh.Vertices)
+		v := make([]int, len(mesh.Vertices))
+		vn := make([]int, len(mesh.Vertices))
+		vt := make([]int, len(mesh.Vertices))
+		for i, p := range mesh.Vertices {
+			v[i] = positions.index(p)
+			if hasNormals {
+				vn[i] = normals.index(mesh.Normals[i])
+			}
+			if hasUVs {
+				vt[i] = uvs.index(mesh.UVs[i])
+			}
+		}
+		for _, f := range mesh.Faces {
+			bw.WriteString("f")
+			for _, i := range f {
+				switch {
+				case hasNormals && hasUVs:
+					fmt.Fprintf(bw, " %d/%d/%d", v[i], vt[i], vn[i])
+				case hasNormals:
+					fmt.Fprintf(bw, " %d//%d", v[i], vn[i])
+				case hasUVs:
+					fmt.Fprintf(bw, " %d/%d", v[i], vt[i])
+				default:
+					fmt.Fprintf(bw, " %d", v[i])
+				}
+			}
+			bw.WriteString("\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteMTL writes materials in wavefront mtl format
+func WriteMTL(w io.Writer, materials []OBJMaterial) error {
+	bw := bufio.NewWriter(w)
+	for i, mat := range materials {
+		fmt.Fprintf(bw, "newmtl %s\n", mat.Name)
+		if mat.Diffuse != nil {
+			r, g, b := colorFloats(mat.Diffuse)
+			fmt.Fprintf(bw, "Kd %s %s %s\n", formatFloat(r), formatFloat(g), formatFloat(b))
+		}
+		if mat.Texture != nil || mat.TextureFile != "" {
+			fmt.Fprintf(bw, "map_Kd %s\n", mat.textureFile(i))
+		}
+	}
+	return bw.Flush()
+}
+
+// vectorIndex writes each distinct vector once as a line
+// starting with prefix, and remembers its 1-based index
+type vectorIndex struct {
+	w       *bufio.Writer
+	prefix  string
+	indices map[m.Vector]int
+}
+
+func newVectorIndex(w *bufio.Writer, prefix string) *vectorIndex {
+	return &vectorIndex{w: w, prefix: prefix, indices: map[m.Vector]int{}}
+}
+
+func (vi *vectorIndex) index(v m.Vector) int {
+	if i, ok := vi.indices[v]; ok {
+		return i
+	}
+	i := len(vi.indices) + 1
+	vi.indices[v] = i
+	if vi.prefix == "vt" {
+		fmt.Fprintf(vi.w, "vt %s %s\n", formatFloat(v.X), formatFloat(v.Y))
+	} else {
+		fmt.Fprintf(vi.w, "%s %s %s %s\n", vi.prefix, formatFloat(v.X), formatFloat(v.Y), formatFloat(v.Z))
+	}
+	return i
+}
+
+func formatFloat(f float32) string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
+
+func colorFloats(c color.Color) (float32, float32, float32) {
+	r, g, b, _ := c.RGBA()
+	return float32(r) / 0xffff, float32(g) / 0xffff, float32(b) / 0xffff
+}
+
+// LoadOBJ reads objects from a wavefront obj file, see ReadOBJ
+func LoadOBJ(filename string) ([]OBJObject, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadOBJ(file)
+}
+
+// a corner of an obj face: 0-based indices into positions, uvs and normals,
+// -1 if not given
+type objCorner struct {
+	v, vt, vn int
+}
+
+// objBuilder turns the corners of the current object into an indexed mesh,
+// with one vertex per distinct combination of position, uv and normal
+type objBuilder struct {
+	object   OBJObject
+	vertices map[objCorner]int
+	corners  []objCorner
+	// all corners have a uv or normal
+	hasUVs, hasNormals bool
+}
+
+func newOBJBuilder(name, material string) *objBuilder {
+	return &objBuilder{
+		object:     OBJObject{Name: name, Material: material},
+		vertices:   map[objCorner]int{},
+		hasUVs:     true,
+		hasNormals: true,
+	}
+}
+
+func (ob *objBuilder) vertex(c objCorner) int {
+	if i, ok := ob.vertices[c]; ok {
+		return i
+	}
+	i := len(ob.corners)
+	ob.vertices[c] = i
+	ob.corners = append(ob.corners, c)
+	ob.hasUVs = ob.hasUVs && c.vt >= 0
+	ob.hasNormals = ob.hasNormals && c.vn >= 0
+	return i
+}
+
+func (ob *objBuilder) build(positions, uvs, normals []m.Vector) OBJObject {
+	mesh := &ob.object.Mesh
+	mesh.Vertices = make([]m.Vector, len(ob.corners))
+	if ob.hasUVs {
+		mesh.UVs = make([]m.Vector, len(ob.corners))
+	}
+	if ob.hasNormals {
+		mesh.Normals = make([]m.Vector, len(ob.corners))
+	}
+	for i, c := range ob.corners {
+		mesh.Vertices[i] = positions[c.v]
+		if ob.hasUVs {
+			mesh.UVs[i] = uvs[c.vt]
+		}
+		if ob.hasNormals {
+			mesh.Normals[i] = normals[c.vn]
+		}
+	}
+	return ob.object
+}
+
+// ReadOBJ reads the geometry from a wavefront obj file.
+// each 'o' statement, or change of material with 'usemtl', starts a new object.
+// polygons are split into triangles fanning out from their first corner.
+// normals and uvs are kept if every face of an object has them.
+// materials files, groups and smoothing are ignored
+func ReadOBJ(r io.Reader) ([]OBJObject, error) {
+	positions, uvs, normals := []m.Vector{}, []m.Vector{}, []m.Vector{}
+	objects := []OBJObject{}
+	current := newOBJBuilder("", "")
+	finish := func() {
+		if len(current.object.Mesh.Faces) > 0 {
+			objects = append(objects, current.build(positions, uvs, normals))
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var err error
+		switch fields[0] {
+		case "v":
+			var v m.Vector
+			v, err = parseVector(fields[1:], 3, 4)
+			positions = append(positions, v)
+		case "vn":
+			var v m.Vector
+			v, err = parseVector(fields[1:], 3, 3)
+			normals = append(normals, v)
+		case "vt":
+			var v m.Vector
+			v, err = parseVector(fields[1:], 1, 3)
+			v.Z = 0
+			uvs = append(uvs, v)
+		case "f":
+			err = current.face(fields[1:], len(positions), len(uvs), len(normals))
+		case "o":
+			finish()
+			current = newOBJBuilder(strings.Join(fields[1:], " "), current.object.Material)
+		case "usemtl":
+			if len(fields) != 2 {
+				err = fmt.Errorf("Invalid material: %v", fields[1:])
+				break
+			}
+			if fields[1] != current.object.Material {
+				finish()
+				current = newOBJBuilder(current.object.Name, fields[1])
+			}
+		case "mtllib", "g", "s", "l", "p":
+		default:
+			err = fmt.Errorf("Unknown statement: %s", fields[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	finish()
+	return objects, nil
+}
+
+// face parses the corners of a face given as v, v/vt, v//vn or v/vt/vn.
+// indices are 1-based, or negative to count back from the last one read
+func (ob *objBuilder) face(fields []string, numV, numVT, numVN int) error {
+	if len(fields) < 3 {
+		return fmt.Errorf("Face with %d corners", len(fields))
+	}
+	indices := make([]int, len(fields))
+	for i, f := range fields {
+		parts := strings.Split(f, "/")
+		if len(parts) > 3 {
+			return fmt.Errorf("Invalid corner: %s", f)
+		}
+		c := objCorner{-1, -1, -1}
+		var err error
+		if c.v, err = objIndex(parts[0], numV); err != nil {
+			return err
+		}
+		if len(parts) > 1 && parts[1] != "" {
+			if c.vt, err = objIndex(parts[1], numVT); err != nil {
+				return err
+			}
+		}
+		if len(parts) > 2 {
+			if c.vn, err = objIndex(parts[2], numVN); err != nil {
+				return err
+			}
+		}
+		indices[i] = ob.vertex(c)
+	}
+	for i := 1; i < len(indices)-1; i++ {
+		ob.object.Mesh.Faces = append(ob.object.Mesh.Faces, [3]int{indices[0], indices[i], indices[i+1]})
+	}
+	return nil
+}
+
+func objIndex(s string, n int) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		i = n + i + 1
+	}
+	if i < 1 || i > n {
+		return 0, fmt.Errorf("Index out of range: %s", s)
+	}
+	return i - 1, nil
+}
+
+// parseVector reads between min and max coordinates, using only the first three
+func parseVector(fields []string, min, max int) (m.Vector, error) {
+	if len(fields) < min || len(fields) > max {
+		return m.Vector{}, fmt.Errorf("Invalid coordinates: %v", fields)
+	}
+	var xyz [3]float32
+	for i, f := range fields {
+		x, err := strconv.ParseFloat(f, 32)
+		if err != nil {
+			return m.Vector{}, err
+		}
+		if i < 3 {
+			xyz[i] = float32(x)
+		}
+	}
+	return m.Vector{xyz[0], xyz[1], xyz[2]}, nil
+}
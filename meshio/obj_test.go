@@ -0,0 +1,183 @@
+package meshio
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+func TestWriteOBJ(t *testing.T) {
+	square := gen.Mesh{
+		Vertices: []m.Vector{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+		Normals:  []m.Vector{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+		Faces:    [][3]int{{0, 1, 2}, {0, 2, 3}},
+	}
+	// second object shares an edge with the first
+	triangle := gen.MeshFromTriangles([]m.Triangle{
+		m.NewTriangle(m.Vector{1, 0, 0}, m.Vector{2, 0, 0}, m.Vector{1, 1, 0}, nil),
+	})
+	objects := []OBJObject{
+		{Name: "square", Mesh: square, Material: "red"},
+		{Name: "triangle", Mesh: triangle},
+	}
+	var buf bytes.Buffer
+	if err := WriteOBJ(&buf, "test.mtl", objects); err != nil {
+		t.Fatal(err)
+	}
+	want := `mtllib test.mtl
+o square
+usemtl red
+v 0 0 0
+vn 0 0 1
+v 1 0 0
+v 1 1 0
+v 0 1 0
+f 1//1 2//1 3//1
+f 1//1 3//1 4//1
+o triangle
+v 2 0 0
+f 2 5 3
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteMTL(t *testing.T) {
+	var buf bytes.Buffer
+	materials := []OBJMaterial{
+		{Name: "red", Diffuse: color.RGBA{255, 0, 0, 255}},
+		{Name: "textured", TextureFile: "grayscott.png"},
+	}
+	if err := WriteMTL(&buf, materials); err != nil {
+		t.Fatal(err)
+	}
+	want := `newmtl red
+Kd 1 0 0
+newmtl textured
+map_Kd grayscott.png
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestReadOBJ(t *testing.T) {
+	input := `# a square and a triangle
+mtllib test.mtl
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vt 1 0
+vt 1 1
+vt 0 1
+vn 0 0 1
+
+o square
+usemtl red
+f 1/1/1 2/2/1 3/3/1 4/4/1
+o triangle
+usemtl blue
+f -3 -2 -1 # relative indices
+`
+	objects, err := ReadOBJ(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("got %d objects want 2", len(objects))
+	}
+	square := objects[0]
+	if square.Name != "square" || square.Material != "red" {
+		t.Errorf("got %q with material %q", square.Name, square.Material)
+	}
+	want := gen.Mesh{
+		Vertices: []m.Vector{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+		Normals:  []m.Vector{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+		UVs:      []m.Vector{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+		Faces:    [][3]int{{0, 1, 2}, {0, 2, 3}},
+	}
+	if !reflect.DeepEqual(square.Mesh, want) {
+		t.Errorf("got %v want %v", square.Mesh, want)
+	}
+	triangle := objects[1]
+	want = gen.Mesh{
+		Vertices: []m.Vector{{1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+		Faces:    [][3]int{{0, 1, 2}},
+	}
+	if triangle.Material != "blue" || !reflect.DeepEqual(triangle.Mesh, want) {
+		t.Errorf("got %v with material %q want %v", triangle.Mesh, triangle.Material, want)
+	}
+}
+
+func TestReadOBJRoundTrip(t *testing.T) {
+	square := gen.Mesh{
+		Vertices: []m.Vector{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+		Normals:  []m.Vector{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+		Faces:    [][3]int{{0, 1, 2}, {0, 2, 3}},
+	}
+	var buf bytes.Buffer
+	if err := WriteOBJ(&buf, "", []OBJObject{{Name: "square", Mesh: square}}); err != nil {
+		t.Fatal(err)
+	}
+	objects, err := ReadOBJ(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || !reflect.DeepEqual(objects[0].Mesh, square) {
+		t.Errorf("got %v want %v", objects, square)
+	}
+}
+
+func TestReadOBJInvalid(t *testing.T) {
+	for i, tt := range []struct {
+		input string
+		err   string
+	}{
+		{"v 0 0\n", "line 1: Invalid coordinates"},
+		{"v 0 0 0\nv 1 0 0\n\nf 1 2\n", "line 4: Face with 2 corners"},
+		{"v 0 0 0\nf 1 2 3\n", "line 2: Index out of range: 2"},
+		{"v 0 0 0\nf 1 1 1/1\n", "line 2: Index out of range: 1"},
+		{"v 0 0 zero\n", "line 1: strconv.ParseFloat"},
+		{"cstype bezier\n", "line 1: Unknown statement: cstype"},
+	} {
+		_, err := ReadOBJ(strings.NewReader(tt.input))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("%d): got error %v want %q", i, err, tt.err)
+		}
+	}
+}
+
+func TestSaveOBJTextureName(t *testing.T) {
+	dir := t.TempDir()
+	texture := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	materials := []OBJMaterial{
+		{Name: "pattern", Texture: texture},
+		{Texture: texture},
+	}
+	if err := SaveOBJ(filepath.Join(dir, "out.obj"), nil, materials); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"pattern.png", "texture1.png"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("texture not written: %s", err)
+		}
+	}
+	mtl, err := os.ReadFile(filepath.Join(dir, "out.mtl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "map_Kd pattern.png\n"; !strings.Contains(string(mtl), want) {
+		t.Errorf("mtl does not reference texture:\n%s", mtl)
+	}
+}
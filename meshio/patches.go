@@ -0,0 +1,211 @@
+package meshio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+// LoadPatches reads a list of bicubic bezier patches from a file, see ReadPatches
+func LoadPatches(filename string) ([]gen.ParametricSurface, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadPatches(file)
+}
+
+// ReadPatches reads a list of bicubic bezier patches in the format
+// of the Utah teapot, teacup, teaspoon and bezier rabbit datasets:
+// the number of patches, a line per patch with 16 vertex indices,
+// the number of vertices and a line per vertex with its coordinates.
+// values are separated by commas and/or whitespace.
+// blank lines and everything after a '#' are ignored
+// NOTE: vertex indices are 1-based
+func ReadPatches(r io.Reader) ([]gen.ParametricSurface, error) {
+	pr := &patchReader{scanner: bufio.NewScanner(r)}
+	numPatches, err := pr.readCount()
+	if err != nil {
+		return nil, err
+	}
+	// counts are not trusted for allocation, the file might be cut short
+	rawPatches, patchLines := [][]int{}, []int{}
+	for i := 0; i < numPatches; i++ {
+		fields, err := pr.expect()
+		if err != nil {
+			return nil, err
+		}
+		patchLines = append(patchLines, pr.lineNumber)
+		if len(fields) != 16 {
+			return nil, pr.errorf("Invalid patch: %d indices", len(fields))
+		}
+		raw := make([]int, len(fields))
+		for j, f := range fields {
+			if raw[j], err = strconv.Atoi(f); err != nil {
+				return nil, pr.errorf("%s", err)
+			}
+		}
+		rawPatches = append(rawPatches, raw)
+	}
+	numVertices, err := pr.readCount()
+	if err != nil {
+		return nil, err
+	}
+	vertices := []m.Vector{}
+	for i := 0; i < numVertices; i++ {
+		fields, err := pr.expect()
+		if err != nil {
+			return nil, err
+		}
+		v, err := parseVector(fields, 3, 3)
+		if err != nil {
+			return nil, pr.errorf("%s", err)
+		}
+		vertices = append(vertices, v)
+	}
+	if fields, err := pr.next(); err != io.ErrUnexpectedEOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, pr.errorf("Unexpected content after vertices: %v", fields)
+	}
+
+	surfaces := make([]gen.ParametricSurface, len(rawPatches))
+	for i, raw := range rawPatches {
+		controlPoints := make([]m.Vector, len(raw))
+		for j, n := range raw {
+			if n < 1 || n > len(vertices) {
+				return nil, fmt.Errorf("line %d: Vertex not found: %d", patchLines[i], n)
+			}
+			controlPoints[j] = vertices[n-1]
+		}
+		patch, err := gen.NewBicubicBezierPatch(controlPoints)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", patchLines[i], err)
+		}
+		surfaces[i] = patch
+	}
+	return surfaces, nil
+}
+
+// patchReader returns the values on each line that is not blank or a comment
+type patchReader struct {
+	scanner    *bufio.Scanner
+	lineNumber int
+}
+
+// next returns io.ErrUnexpectedEOF if there are no more lines
+func (pr *patchReader) next() ([]string, error) {
+	for pr.scanner.Scan() {
+		pr.lineNumber++
+		line := pr.scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\r'
+		})
+		if len(fields) > 0 {
+			return fields, nil
+		}
+	}
+	if err := pr.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// expect is next, but running out of lines is an error
+func (pr *patchReader) expect() ([]string, error) {
+	fields, err := pr.next()
+	if err == io.ErrUnexpectedEOF {
+		return nil, pr.errorf("Unexpected end of file")
+	}
+	return fields, err
+}
+
+func (pr *patchReader) readCount() (int, error) {
+	fields, err := pr.expect()
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) != 1 {
+		return 0, pr.errorf("Expected a count: %v", fields)
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n < 0 {
+		return 0, pr.errorf("Invalid count: %s", fields[0])
+	}
+	return n, nil
+}
+
+func (pr *patchReader) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("line %d: %s", pr.lineNumber, fmt.Sprintf(format, a...))
+}
+
+// SavePatches writes bicubic bezier patches to a file, see WritePatches
+func SavePatches(filename string, patches []gen.ParametricSurface) (err error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer closeFile(file, &err)
+	return WritePatches(file, patches)
+}
+
+// bezierPatch is implemented by the bezier patches in gen
+type bezierPatch interface {
+	Degree() (int, int)
+	ControlPoints() []m.Vector
+}
+
+// WritePatches writes bicubic bezier patches in the format read by ReadPatches.
+// control points in exactly the same position are written as one vertex,
+// in the order they are first used
+func WritePatches(w io.Writer, patches []gen.ParametricSurface) error {
+	indices := map[m.Vector]int{}
+	vertices := []m.Vector{}
+	rawPatches := make([][]int, len(patches))
+	for i, p := range patches {
+		bp, ok := p.(bezierPatch)
+		if !ok {
+			return fmt.Errorf("Patch %d: not a bezier patch: %T", i, p)
+		}
+		if u, v := bp.Degree(); u != 3 || v != 3 {
+			return fmt.Errorf("Patch %d: not bicubic: %dx%d", i, u, v)
+		}
+		for _, c := range bp.ControlPoints() {
+			index, ok := indices[c]
+			if !ok {
+				vertices = append(vertices, c)
+				index = len(vertices)
+				indices[c] = index
+			}
+			rawPatches[i] = append(rawPatches[i], index)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%d\n", len(rawPatches))
+	for _, raw := range rawPatches {
+		for j, index := range raw {
+			if j > 0 {
+				bw.WriteString(",")
+			}
+			bw.WriteString(strconv.Itoa(index))
+		}
+		bw.WriteString("\n")
+	}
+	fmt.Fprintf(bw, "%d\n", len(vertices))
+	for _, v := range vertices {
+		fmt.Fprintf(bw, "%s,%s,%s\n", formatFloat(v.X), formatFloat(v.Y), formatFloat(v.Z))
+	}
+	return bw.Flush()
+}
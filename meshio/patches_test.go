@@ -0,0 +1,143 @@
+package meshio
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+func TestLoadPatchesTeapot(t *testing.T) {
+	patches, err := LoadPatches("../teapot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 32 {
+		t.Errorf("got %d patches want 32", len(patches))
+	}
+	// first control point of the first patch is vertex 1
+	if got, want := patches[0].Evaluate(0, 0), (m.Vector{1.4, 0, 2.4}); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+// a flat patch on a 4x4 grid, written with spaces and comments
+const flatPatch = `# a single patch
+1
+
+1 2 3 4  5 6 7 8  9 10 11 12  13 14 15 16
+16
+0 0 0
+1 0 0
+2 0 0
+3 0 0 # end of first row
+0 1 0
+1 1 0
+2 1 0
+3 1 0
+0, 2, 0
+1, 2, 0
+2, 2, 0
+3, 2, 0
+0,3,0
+1,3,0
+2,3,0
+3,3,0
+`
+
+func TestReadPatches(t *testing.T) {
+	patches, err := ReadPatches(strings.NewReader(strings.ReplaceAll(flatPatch, "\n", "\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 1 {
+		t.Fatalf("got %d patches want 1", len(patches))
+	}
+	if got, want := patches[0].Evaluate(0.5, 0.5), (m.Vector{1.5, 1.5, 0}); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestReadPatchesInvalid(t *testing.T) {
+	for i, tt := range []struct {
+		input string
+		err   string
+	}{
+		{"", "line 0: Unexpected end of file"},
+		{"one\n", "line 1: Invalid count: one"},
+		{"1\n1,2,3\n", "line 2: Invalid patch: 3 indices"},
+		{strings.Replace(flatPatch, "16\n0 0 0", "15\n0 0 0", 1), "line 21: Unexpected content after vertices"},
+		{strings.Replace(flatPatch, "16\n0 0 0", "17\n0 0 0", 1), "line 21: Unexpected end of file"},
+		{strings.Replace(flatPatch, "15 16", "15 17", 1), "line 4: Vertex not found: 17"},
+		{strings.Replace(flatPatch, "2 1 0", "2 1", 1), "line 12: Invalid coordinates"},
+	} {
+		_, err := ReadPatches(strings.NewReader(tt.input))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("%d): got error %v want %q", i, err, tt.err)
+		}
+	}
+}
+
+func TestWritePatchesRoundTrip(t *testing.T) {
+	patches, err := LoadPatches("../teapot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// move one control point shared by the first two patches
+	points := patches[0].(bezierPatch).ControlPoints()
+	moved := points[3]
+	points[3] = m.Vector{1, 2, 3}
+	patches[0], err = gen.NewBicubicBezierPatch(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePatches(&buf, patches); err != nil {
+		t.Fatal(err)
+	}
+	written := buf.String()
+	read, err := ReadPatches(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(patches) {
+		t.Fatalf("got %d patches want %d", len(read), len(patches))
+	}
+	for i := range patches {
+		got, want := read[i].(bezierPatch).ControlPoints(), patches[i].(bezierPatch).ControlPoints()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("patch %d: got %v want %v", i, got, want)
+		}
+	}
+	// the second patch still uses the old position, so both are kept
+	if got := read[1].(bezierPatch).ControlPoints()[0]; got != moved {
+		t.Errorf("got %v want %v", got, moved)
+	}
+
+	buf.Reset()
+	if err := WritePatches(&buf, read); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != written {
+		t.Error("writing patches read back gives a different file")
+	}
+}
+
+func TestWritePatchesDeduplicates(t *testing.T) {
+	patches, err := ReadPatches(strings.NewReader(flatPatch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WritePatches(&buf, append(patches, patches[0])); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "2" || lines[1] != lines[2] || lines[3] != "16" || lines[4] != "0,0,0" {
+		t.Errorf("got %q", lines[:5])
+	}
+}
@@ -0,0 +1,385 @@
+package meshio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+// LoadPLY reads a mesh from a ply file, see ReadPLY
+func LoadPLY(filename string) (gen.Mesh, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return gen.Mesh{}, err
+	}
+	defer file.Close()
+	return ReadPLY(file)
+}
+
+type plyProperty struct {
+	name string
+	typ  string
+	// lists are prefixed with their length, of type countType
+	list      bool
+	countType string
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+// bytes per value of each ply type
+var plyTypeSizes = map[string]int{
+	"char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4, "uint": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8,
+}
+
+// ReadPLY reads the vertex and face elements of an ascii or binary ply file.
+// vertex properties x, y and z are required; nx, ny and nz are read as normals
+// and u and v (or s and t, texture_u and texture_v) as uvs.
+// faces are read from the vertex_indices (or vertex_index) list and
+// polygons are split into triangles fanning out from their first corner.
+// all other elements and properties are skipped
+func ReadPLY(r io.Reader) (gen.Mesh, error) {
+	br := bufio.NewReader(r)
+	format, elements, lineNumber, err := readPLYHeader(br)
+	if err != nil {
+		return gen.Mesh{}, err
+	}
+	var values plyValues
+	switch format {
+	case "ascii":
+		values = &plyASCII{r: br, lineNumber: lineNumber}
+	case "binary_little_endian":
+		values = &plyBinary{r: br, order: binary.LittleEndian}
+	case "binary_big_endian":
+		values = &plyBinary{r: br, order: binary.BigEndian}
+	default:
+		return gen.Mesh{}, fmt.Errorf("Unknown ply format: %s", format)
+	}
+
+	mesh := gen.Mesh{}
+	for _, e := range elements {
+		var err error
+		switch e.name {
+		case "vertex":
+			err = readPLYVertices(values, e, &mesh)
+		case "face":
+			err = readPLYFaces(values, e, &mesh)
+		default:
+			err = skipPLYElement(values, e)
+		}
+		if err != nil {
+			return gen.Mesh{}, fmt.Errorf("%s: %s", values.position(), err)
+		}
+	}
+	for i, f := range mesh.Faces {
+		for _, index := range f {
+			if index < 0 || index >= len(mesh.Vertices) {
+				return gen.Mesh{}, fmt.Errorf("Face %d: vertex not found: %d", i, index)
+			}
+		}
+	}
+	return mesh, nil
+}
+
+func readPLYHeader(r *bufio.Reader) (string, []plyElement, int, error) {
+	var format string
+	elements := []plyElement{}
+	lineNumber := 0
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("Unexpected end of ply header")
+			}
+			return "", nil, lineNumber, err
+		}
+		lineNumber++
+		fields := strings.Fields(line)
+		if lineNumber == 1 {
+			if len(fields) != 1 || fields[0] != "ply" {
+				return "", nil, lineNumber, fmt.Errorf("Not a ply file")
+			}
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		invalid := fmt.Errorf("line %d: Invalid header line: %s", lineNumber, strings.TrimSpace(line))
+		switch fields[0] {
+		case "format":
+			if len(fields) != 3 || fields[2] != "1.0" {
+				return "", nil, lineNumber, invalid
+			}
+			format = fields[1]
+		case "element":
+			if len(fields) != 3 {
+				return "", nil, lineNumber, invalid
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return "", nil, lineNumber, invalid
+			}
+			elements = append(elements, plyElement{name: fields[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return "", nil, lineNumber, invalid
+			}
+			var p plyProperty
+			switch {
+			case len(fields) == 3:
+				p = plyProperty{name: fields[2], typ: fields[1]}
+			case len(fields) == 5 && fields[1] == "list":
+				p = plyProperty{name: fields[4], typ: fields[3], list: true, countType: fields[2]}
+				if _, ok := plyTypeSizes[p.countType]; !ok {
+					return "", nil, lineNumber, invalid
+				}
+			default:
+				return "", nil, lineNumber, invalid
+			}
+			if _, ok := plyTypeSizes[p.typ]; !ok {
+				return "", nil, lineNumber, invalid
+			}
+			e := &elements[len(elements)-1]
+			e.properties = append(e.properties, p)
+		case "comment", "obj_info":
+		case "end_header":
+			if format == "" {
+				return "", nil, lineNumber, fmt.Errorf("line %d: Missing ply format", lineNumber)
+			}
+			return format, elements, lineNumber, nil
+		default:
+			return "", nil, lineNumber, invalid
+		}
+	}
+}
+
+// readPLYVertices reads all properties of each vertex,
+// keeping those it knows by name
+func readPLYVertices(values plyValues, e plyElement, mesh *gen.Mesh) error {
+	index := map[string]int{}
+	for i, p := range e.properties {
+		if p.list {
+			continue
+		}
+		index[p.name] = i
+	}
+	has := func(names ...string) bool {
+		for _, n := range names {
+			if _, ok := index[n]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	if !has("x", "y", "z") {
+		return fmt.Errorf("Vertex without x, y and z")
+	}
+	hasNormals := has("nx", "ny", "nz")
+	u, v := "", ""
+	for _, names := range [][2]string{{"u", "v"}, {"s", "t"}, {"texture_u", "texture_v"}} {
+		if has(names[0], names[1]) {
+			u, v = names[0], names[1]
+			break
+		}
+	}
+
+	row := make([]float64, len(e.properties))
+	for i := 0; i < e.count; i++ {
+		for j, p := range e.properties {
+			if p.list {
+				if _, err := readPLYList(values, p); err != nil {
+					return err
+				}
+				continue
+			}
+			x, err := values.next(p.typ)
+			if err != nil {
+				return err
+			}
+			row[j] = x
+		}
+		vector := func(x, y, z string) m.Vector {
+			var zf float32
+			if z != "" {
+				zf = float32(row[index[z]])
+			}
+			return m.Vector{float32(row[index[x]]), float32(row[index[y]]), zf}
+		}
+		mesh.Vertices = append(mesh.Vertices, vector("x", "y", "z"))
+		if hasNormals {
+			mesh.Normals = append(mesh.Normals, vector("nx", "ny", "nz"))
+		}
+		if u != "" {
+			mesh.UVs = append(mesh.UVs, vector(u, v, ""))
+		}
+	}
+	return nil
+}
+
+func readPLYFaces(values plyValues, e plyElement, mesh *gen.Mesh) error {
+	found := false
+	for _, p := range e.properties {
+		if p.list && (p.name == "vertex_indices" || p.name == "vertex_index") {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("Face without vertex_indices")
+	}
+	for i := 0; i < e.count; i++ {
+		for _, p := range e.properties {
+			if !p.list {
+				if _, err := values.next(p.typ); err != nil {
+					return err
+				}
+				continue
+			}
+			list, err := readPLYList(values, p)
+			if err != nil {
+				return err
+			}
+			if p.name != "vertex_indices" && p.name != "vertex_index" {
+				continue
+			}
+			if len(list) < 3 {
+				return fmt.Errorf("Face with %d corners", len(list))
+			}
+			for j := 1; j < len(list)-1; j++ {
+				mesh.Faces = append(mesh.Faces, [3]int{int(list[0]), int(list[j]), int(list[j+1])})
+			}
+		}
+	}
+	return nil
+}
+
+func skipPLYElement(values plyValues, e plyElement) error {
+	for i := 0; i < e.count; i++ {
+		for _, p := range e.properties {
+			var err error
+			if p.list {
+				_, err = readPLYList(values, p)
+			} else {
+				_, err = values.next(p.typ)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readPLYList(values plyValues, p plyProperty) ([]float64, error) {
+	n, err := values.next(p.countType)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("Invalid list length: %v", n)
+	}
+	// the length comes from the file, so grow the list while reading
+	// instead of trusting it up front with an allocation
+	var list []float64
+	for i := 0; i < int(n); i++ {
+		v, err := values.next(p.typ)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// plyValues reads the body of a ply file one value at a time
+type plyValues interface {
+	next(typ string) (float64, error)
+	// where reading stopped, for error messages
+	position() string
+}
+
+// plyASCII reads whitespace separated values, one element per line
+// NOTE: elements are not checked to end at the end of a line
+type plyASCII struct {
+	r          *bufio.Reader
+	fields     []string
+	lineNumber int
+}
+
+func (p *plyASCII) next(typ string) (float64, error) {
+	for len(p.fields) == 0 {
+		line, err := p.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		p.lineNumber++
+		p.fields = strings.Fields(line)
+	}
+	field := p.fields[0]
+	p.fields = p.fields[1:]
+	if typ == "float" || typ == "float32" || typ == "double" || typ == "float64" {
+		return strconv.ParseFloat(field, 64)
+	}
+	i, err := strconv.ParseInt(field, 10, 64)
+	return float64(i), err
+}
+
+func (p *plyASCII) position() string {
+	return fmt.Sprintf("line %d", p.lineNumber)
+}
+
+type plyBinary struct {
+	r      io.Reader
+	order  binary.ByteOrder
+	buf    [8]byte
+	offset int
+}
+
+func (p *plyBinary) next(typ string) (float64, error) {
+	b := p.buf[:plyTypeSizes[typ]]
+	if _, err := io.ReadFull(p.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	p.offset += len(b)
+	switch typ {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(p.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(p.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(p.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(p.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(p.order.Uint32(b))), nil
+	default:
+		return math.Float64frombits(p.order.Uint64(b)), nil
+	}
+}
+
+func (p *plyBinary) position() string {
+	return fmt.Sprintf("byte %d of body", p.offset)
+}
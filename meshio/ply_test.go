@@ -0,0 +1,118 @@
+package meshio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+func TestReadPLY(t *testing.T) {
+	ascii := `ply
+format ascii 1.0
+comment a square
+element vertex 4
+property float x
+property float y
+property float z
+property float nx
+property float ny
+property float nz
+property float s
+property float t
+element face 1
+property uchar intensity
+property list uchar int vertex_indices
+element edge 1
+property int vertex1
+property int vertex2
+end_header
+0 0 0 0 0 1 0 0
+1 0 0 0 0 1 1 0
+1 1 0 0 0 1 1 1
+0 1 0 0 0 1 0 1
+255 4 0 1 2 3
+0 1
+`
+	// same square in binary, without uvs and with a colour per vertex
+	var buf bytes.Buffer
+	buf.WriteString(`ply
+format binary_little_endian 1.0
+element vertex 4
+property double x
+property double y
+property double z
+property float nx
+property float ny
+property float nz
+property uchar red
+element face 1
+property list uchar uint vertex_indices
+end_header
+`)
+	for _, v := range []m.Vector{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}} {
+		binary.Write(&buf, binary.LittleEndian, []float64{float64(v.X), float64(v.Y), float64(v.Z)})
+		binary.Write(&buf, binary.LittleEndian, []float32{0, 0, 1})
+		buf.WriteByte(255)
+	}
+	buf.WriteByte(4)
+	binary.Write(&buf, binary.LittleEndian, []uint32{0, 1, 2, 3})
+
+	want := gen.Mesh{
+		Vertices: []m.Vector{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+		Normals:  []m.Vector{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+		UVs:      []m.Vector{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+		Faces:    [][3]int{{0, 1, 2}, {0, 2, 3}},
+	}
+	mesh, err := ReadPLY(strings.NewReader(ascii))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mesh, want) {
+		t.Errorf("ascii: got %v want %v", mesh, want)
+	}
+	want.UVs = nil
+	mesh, err = ReadPLY(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mesh, want) {
+		t.Errorf("binary: got %v want %v", mesh, want)
+	}
+}
+
+func TestReadPLYInvalid(t *testing.T) {
+	header := "ply\nformat ascii 1.0\nelement vertex 3\nproperty float x\nproperty float y\nproperty float z\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n"
+	for i, tt := range []struct {
+		input string
+		err   string
+	}{
+		{"obj\n", "Not a ply file"},
+		{"ply\nformat ascii 1.0\nelement vertex 1\nproperty quad x\nend_header\n", "line 4: Invalid header line"},
+		{"ply\nformat ascii 1.0\nelement vertex 1\n", "Unexpected end of ply header"},
+		{"ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nend_header\n0\n", "line 5: Vertex without x, y and z"},
+		{header + "0 0 0\n1 0 0\n0 1 0\n3 0 1 5\n", "Face 0: vertex not found: 5"},
+		{header + "0 0 0\n1 0 0\n0 1 0\n2 0 1\n", "line 13: Face with 2 corners"},
+		{header + "0 0 0\n1 0 zero\n", "line 11: strconv.ParseFloat"},
+		{header + "0 0 0\n1 0 0\n", "line 11: unexpected EOF"},
+	} {
+		_, err := ReadPLY(strings.NewReader(tt.input))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("%d): got error %v want %q", i, err, tt.err)
+		}
+	}
+}
+
+func TestReadPLYHugeList(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("ply\nformat binary_little_endian 1.0\nelement face 1\nproperty list uint uint vertex_indices\nend_header\n")
+	// a count of four billion with only a few values behind it
+	binary.Write(&buf, binary.LittleEndian, []uint32{4000000000, 0, 1, 2})
+	if _, err := ReadPLY(&buf); err == nil {
+		t.Error("expected error for truncated list")
+	}
+}
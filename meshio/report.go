@@ -0,0 +1,147 @@
+package meshio
+
+import (
+	"fmt"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+// MeshReport lists problems that keep a mesh from being a closed solid
+type MeshReport struct {
+	Faces int
+	// edges used by more than two faces
+	NonManifoldEdges int
+	// edges used by only one face: holes or open borders
+	BoundaryEdges int
+	// faces wound opposite to their neighbours, or facing inwards on a closed part
+	InvertedFacets int
+	// faces with zero area
+	DegenerateFacets int
+}
+
+func (r MeshReport) Watertight() bool {
+	return r.NonManifoldEdges == 0 && r.BoundaryEdges == 0 && r.InvertedFacets == 0
+}
+
+func (r MeshReport) String() string {
+	return fmt.Sprintf("%d faces: %d non-manifold edges, %d boundary edges, %d inverted facets, %d degenerate facets",
+		r.Faces, r.NonManifoldEdges, r.BoundaryEdges, r.InvertedFacets, r.DegenerateFacets)
+}
+
+type edge [2]int
+
+// a face using an edge, and whether it runs along the edge from low to high index
+type edgeUse struct {
+	face    int
+	forward bool
+}
+
+// InspectMesh checks the topology of an indexed mesh.
+// vertices are only considered the same if they have the same index,
+// so build meshes from triangles with gen.MeshFromTriangles first
+func InspectMesh(mesh gen.Mesh) MeshReport {
+	report := MeshReport{Faces: len(mesh.Faces)}
+	edges := map[edge][]edgeUse{}
+	for i, f := range mesh.Faces {
+		p0, p1, p2 := mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]]
+		if m.VectorFromTo(p0, p1).Cross(m.VectorFromTo(p0, p2)).Length() == 0 {
+			report.DegenerateFacets++
+		}
+		for j := 0; j < 3; j++ {
+			a, b := f[j], f[(j+1)%3]
+			if a < b {
+				edges[edge{a, b}] = append(edges[edge{a, b}], edgeUse{i, true})
+			} else {
+				edges[edge{b, a}] = append(edges[edge{b, a}], edgeUse{i, false})
+			}
+		}
+	}
+	for _, uses := range edges {
+		switch {
+		case len(uses) == 1:
+			report.BoundaryEdges++
+		case len(uses) > 2:
+			report.NonManifoldEdges++
+		}
+	}
+	report.InvertedFacets = invertedFacets(mesh, edges)
+	return report
+}
+
+// invertedFacets walks each connected part of the mesh across manifold edges,
+// flipping the orientation it expects whenever two faces run along
+// their shared edge in the same direction. for closed parts the orientation
+// with positive volume is correct, for open parts the majority wins
+func invertedFacets(mesh gen.Mesh, edges map[edge][]edgeUse) int {
+	neighbours := make([][]edgeUse, len(mesh.Faces))
+	for _, uses := range edges {
+		if len(uses) != 2 {
+			continue
+		}
+		a, b := uses[0], uses[1]
+		// consistent winding means opposite directions along the edge
+		consistent := a.forward != b.forward
+		neighbours[a.face] = append(neighbours[a.face], edgeUse{b.face, consistent})
+		neighbours[b.face] = append(neighbours[b.face], edgeUse{a.face, consistent})
+	}
+
+	inverted := 0
+	// orientation relative to the first face of its part, 0 if not visited yet
+	orientation := make([]int, len(mesh.Faces))
+	for start := range mesh.Faces {
+		if orientation[start] != 0 {
+			continue
+		}
+		orientation[start] = 1
+		part := []int{start}
+		closed := true
+		for queue := []int{start}; len(queue) > 0; queue = queue[1:] {
+			f := queue[0]
+			for _, n := range neighbours[f] {
+				o := orientation[f]
+				if !n.forward {
+					o = -o
+				}
+				if orientation[n.face] == 0 {
+					orientation[n.face] = o
+					part = append(part, n.face)
+					queue = append(queue, n.face)
+				}
+			}
+		}
+		flipped := 0
+		var volume float32
+		for _, f := range part {
+			if orientation[f] < 0 {
+				flipped++
+			}
+			face := mesh.Faces[f]
+			for j := 0; j < 3; j++ {
+				a, b := face[j], face[(j+1)%3]
+				if a > b {
+					a, b = b, a
+				}
+				if len(edges[edge{a, b}]) != 2 {
+					closed = false
+				}
+			}
+			// signed volume of the tetrahedron with the origin,
+			// counted as if the face had the orientation of the start face
+			p0, p1, p2 := mesh.Vertices[face[0]], mesh.Vertices[face[1]], mesh.Vertices[face[2]]
+			volume += float32(orientation[f]) * p0.Dot(p1.Cross(p2))
+		}
+		switch {
+		case closed && volume < 0:
+			// the start face itself faces inwards
+			inverted += len(part) - flipped
+		case closed:
+			inverted += flipped
+		case flipped > len(part)-flipped:
+			inverted += len(part) - flipped
+		default:
+			inverted += flipped
+		}
+	}
+	return inverted
+}
@@ -0,0 +1,122 @@
+package meshio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+type STLOptions struct {
+	// write ascii instead of binary stl
+	ASCII bool
+	// name of the solid in ascii stl, or start of the binary header
+	Name string
+	// refuse to write meshes that are not watertight
+	RequireWatertight bool
+}
+
+// SaveSTL inspects the mesh, then writes it to filename.
+// the report is returned even if the mesh is refused or writing fails
+func SaveSTL(filename string, mesh gen.Mesh, options STLOptions) (report MeshReport, err error) {
+	report, err = inspectSTL(mesh, options)
+	if err != nil {
+		return report, err
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return report, err
+	}
+	defer closeFile(file, &err)
+	return report, writeSTL(file, mesh, options)
+}
+
+// SaveTrianglesSTL saves separate triangles, such as those from JoinPoints,
+// sphere.Triangulate or ExtrudeSolidFaceTriangles, as an stl file.
+// triangles are joined where their vertices are in exactly the same position
+func SaveTrianglesSTL(filename string, triangles []m.Triangle, options STLOptions) (MeshReport, error) {
+	return SaveSTL(filename, gen.MeshFromTriangles(triangles), options)
+}
+
+// WriteSTL inspects the mesh, then writes its faces as stl facets
+// with normals computed from the winding of each face
+func WriteSTL(w io.Writer, mesh gen.Mesh, options STLOptions) (MeshReport, error) {
+	report, err := inspectSTL(mesh, options)
+	if err != nil {
+		return report, err
+	}
+	return report, writeSTL(w, mesh, options)
+}
+
+func inspectSTL(mesh gen.Mesh, options STLOptions) (MeshReport, error) {
+	report := InspectMesh(mesh)
+	if options.RequireWatertight && !report.Watertight() {
+		return report, fmt.Errorf("Mesh not watertight: %s", report)
+	}
+	// binary stl counts facets in a uint32
+	if !options.ASCII && uint64(len(mesh.Faces)) > math.MaxUint32 {
+		return report, fmt.Errorf("Too many faces for stl: %d", len(mesh.Faces))
+	}
+	return report, nil
+}
+
+func writeSTL(w io.Writer, mesh gen.Mesh, options STLOptions) error {
+	if options.ASCII {
+		return writeASCIISTL(w, mesh, options.Name)
+	}
+	return writeBinarySTL(w, mesh, options.Name)
+}
+
+func faceNormal(p0, p1, p2 m.Vector) m.Vector {
+	n := m.VectorFromTo(p0, p1).Cross(m.VectorFromTo(p0, p2))
+	if n.Length() == 0 {
+		return n
+	}
+	return n.Normalize()
+}
+
+func writeASCIISTL(w io.Writer, mesh gen.Mesh, name string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "solid %s\n", name)
+	for _, f := range mesh.Faces {
+		p0, p1, p2 := mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]]
+		n := faceNormal(p0, p1, p2)
+		fmt.Fprintf(bw, "facet normal %s %s %s\n", formatFloat(n.X), formatFloat(n.Y), formatFloat(n.Z))
+		bw.WriteString("outer loop\n")
+		for _, p := range []m.Vector{p0, p1, p2} {
+			fmt.Fprintf(bw, "vertex %s %s %s\n", formatFloat(p.X), formatFloat(p.Y), formatFloat(p.Z))
+		}
+		bw.WriteString("endloop\nendfacet\n")
+	}
+	fmt.Fprintf(bw, "endsolid %s\n", name)
+	return bw.Flush()
+}
+
+// binary stl: 80 byte header, number of facets as uint32,
+// then per facet normal and vertices as float32 and a uint16 attribute
+// all little endian
+func writeBinarySTL(w io.Writer, mesh gen.Mesh, name string) error {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 80)
+	copy(header, name)
+	bw.Write(header)
+	buf := make([]byte, 50)
+	binary.LittleEndian.PutUint32(buf, uint32(len(mesh.Faces)))
+	bw.Write(buf[:4])
+	for _, f := range mesh.Faces {
+		p0, p1, p2 := mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]]
+		for i, v := range []m.Vector{faceNormal(p0, p1, p2), p0, p1, p2} {
+			binary.LittleEndian.PutUint32(buf[i*12:], math.Float32bits(v.X))
+			binary.LittleEndian.PutUint32(buf[i*12+4:], math.Float32bits(v.Y))
+			binary.LittleEndian.PutUint32(buf[i*12+8:], math.Float32bits(v.Z))
+		}
+		binary.LittleEndian.PutUint16(buf[48:], 0)
+		bw.Write(buf)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,119 @@
+package meshio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+// unit cube with all faces wound counterclockwise seen from outside
+func cube() gen.Mesh {
+	return gen.Mesh{
+		Vertices: []m.Vector{
+			{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0},
+			{0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1},
+		},
+		Faces: [][3]int{
+			{0, 2, 1}, {0, 3, 2}, // bottom
+			{4, 5, 6}, {4, 6, 7}, // top
+			{0, 1, 5}, {0, 5, 4}, // front
+			{2, 3, 7}, {2, 7, 6}, // back
+			{0, 4, 7}, {0, 7, 3}, // left
+			{1, 2, 6}, {1, 6, 5}, // right
+		},
+	}
+}
+
+func TestInspectMesh(t *testing.T) {
+	closed := cube()
+	open := cube()
+	open.Faces = open.Faces[1:]
+	flipped := cube()
+	flipped.Faces[0] = [3]int{0, 1, 2}
+	inside := cube()
+	for i, f := range inside.Faces {
+		inside.Faces[i] = [3]int{f[0], f[2], f[1]}
+	}
+	finned := cube()
+	finned.Vertices = append(finned.Vertices, m.Vector{0.5, -1, 0})
+	finned.Faces = append(finned.Faces, [3]int{0, 1, 8})
+
+	for i, tt := range []struct {
+		mesh gen.Mesh
+		want MeshReport
+	}{
+		{
+			mesh: closed,
+			want: MeshReport{Faces: 12},
+		},
+		{
+			mesh: open,
+			want: MeshReport{Faces: 11, BoundaryEdges: 3},
+		},
+		{
+			mesh: flipped,
+			want: MeshReport{Faces: 12, InvertedFacets: 1},
+		},
+		{
+			mesh: inside,
+			want: MeshReport{Faces: 12, InvertedFacets: 12},
+		},
+		{
+			mesh: finned,
+			want: MeshReport{Faces: 13, NonManifoldEdges: 1, BoundaryEdges: 2},
+		},
+	} {
+		got := InspectMesh(tt.mesh)
+		if got != tt.want {
+			t.Errorf("%d): got %v want %v", i, got, tt.want)
+		}
+		if got.Watertight() != (i == 0) {
+			t.Errorf("%d): watertight %t", i, got.Watertight())
+		}
+	}
+}
+
+func TestWriteSTL(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := WriteSTL(&buf, cube(), STLOptions{Name: "cube", RequireWatertight: true}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if len(b) != 84+12*50 {
+		t.Fatalf("got %d bytes want %d", len(b), 84+12*50)
+	}
+	if !bytes.HasPrefix(b, []byte("cube\x00")) {
+		t.Errorf("header %q", b[:80])
+	}
+	if n := binary.LittleEndian.Uint32(b[80:]); n != 12 {
+		t.Errorf("got %d facets want 12", n)
+	}
+
+	buf.Reset()
+	if _, err := WriteSTL(&buf, cube(), STLOptions{ASCII: true, Name: "cube"}); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+	want := "solid cube\nfacet normal 0 0 -1\nouter loop\nvertex 0 0 0\nvertex 1 1 0\nvertex 1 0 0\nendloop\nendfacet\n"
+	if !strings.HasPrefix(s, want) {
+		t.Errorf("got %q want prefix %q", s[:len(want)], want)
+	}
+	if !strings.HasSuffix(s, "endsolid cube\n") {
+		t.Errorf("missing endsolid")
+	}
+
+	buf.Reset()
+	open := cube()
+	open.Faces = open.Faces[1:]
+	report, err := WriteSTL(&buf, open, STLOptions{RequireWatertight: true})
+	if err == nil {
+		t.Error("expected error for open mesh")
+	}
+	if report.BoundaryEdges != 3 || buf.Len() != 0 {
+		t.Errorf("got %v and %d bytes written", report, buf.Len())
+	}
+}
@@ -0,0 +1,160 @@
+package meshio
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+// SVGOptions control how l-system segments are drawn.
+// zero values get defaults: a 512x512 image with a margin of 8,
+// black branches of width 1 and green leaves
+type SVGOptions struct {
+	Width, Height float64
+	Margin        float64
+	Stroke        string
+	Fill          string
+	// Background is not drawn if empty
+	Background string
+	// StrokeWidth returns the width of a branch drawn inside depth
+	// brackets; nil means a width of 1 everywhere
+	StrokeWidth func(depth int) float64
+}
+
+func (o *SVGOptions) setDefaults() {
+	if o.Width == 0 {
+		o.Width = 512
+	}
+	if o.Height == 0 {
+		o.Height = 512
+	}
+	if o.Margin == 0 {
+		o.Margin = 8
+	}
+	if o.Stroke == "" {
+		o.Stroke = "black"
+	}
+	if o.Fill == "" {
+		o.Fill = "green"
+	}
+	if o.StrokeWidth == nil {
+		o.StrokeWidth = func(int) float64 { return 1 }
+	}
+}
+
+// SaveSVG draws segments to filename, see WriteSVG
+func SaveSVG(filename string, segments []gen.Lsegment, options SVGOptions) (err error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer closeFile(file, &err)
+	return WriteSVG(file, segments, options)
+}
+
+// WriteSVG draws the x and y coordinates of l-system segments as svg,
+// scaled to fit the image with y pointing up. branches are polylines,
+// split where their depth changes if stroke width depends on depth;
+// leaves are filled polygons
+func WriteSVG(w io.Writer, segments []gen.Lsegment, options SVGOptions) error {
+	perDepth := options.StrokeWidth != nil
+	options.setDefaults()
+	project := svgProjection(segments, options)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %s %s\">\n",
+		svgFloat(options.Width), svgFloat(options.Height), svgFloat(options.Width), svgFloat(options.Height))
+	if options.Background != "" {
+		fmt.Fprintf(bw, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", html.EscapeString(options.Background))
+	}
+	fmt.Fprintf(bw, "<g fill=\"none\" stroke=\"%s\" stroke-linecap=\"round\" stroke-linejoin=\"round\">\n", html.EscapeString(options.Stroke))
+	for _, s := range segments {
+		points := s.GetPoints()
+		if len(points) < 2 {
+			continue
+		}
+		if _, ok := s.(gen.Lleaf); ok {
+			fmt.Fprintf(bw, "<polygon points=\"%s\" fill=\"%s\" stroke=\"none\"/>\n", svgPoints(points, project), html.EscapeString(options.Fill))
+			continue
+		}
+		depths := make([]int, len(points))
+		if b, ok := s.(gen.Lbranch); ok && perDepth && len(b.GetDepths()) == len(points) {
+			depths = b.GetDepths()
+		}
+		// a run of points drawn at the same depth, starting from
+		// the last point of the previous run
+		start := 0
+		for i := 1; i < len(points); i++ {
+			if i < len(points)-1 && depths[i+1] == depths[i] {
+				continue
+			}
+			fmt.Fprintf(bw, "<polyline points=\"%s\" stroke-width=\"%s\"/>\n",
+				svgPoints(points[start:i+1], project), svgFloat(options.StrokeWidth(depths[i])))
+			start = i
+		}
+	}
+	bw.WriteString("</g>\n</svg>\n")
+	return bw.Flush()
+}
+
+// svgProjection fits the bounding box of all points inside the margins,
+// keeping the aspect ratio, and flips y
+func svgProjection(segments []gen.Lsegment, options SVGOptions) func(m.Vector) (float64, float64) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, s := range segments {
+		for _, p := range s.GetPoints() {
+			minX, maxX = math.Min(minX, float64(p.X)), math.Max(maxX, float64(p.X))
+			minY, maxY = math.Min(minY, float64(p.Y)), math.Max(maxY, float64(p.Y))
+		}
+	}
+	if minX > maxX {
+		minX, maxX, minY, maxY = 0, 0, 0, 0
+	}
+	w, h := options.Width-2*options.Margin, options.Height-2*options.Margin
+	scale := math.Inf(1)
+	if maxX > minX {
+		scale = w / (maxX - minX)
+	}
+	if maxY > minY {
+		scale = math.Min(scale, h/(maxY-minY))
+	}
+	if math.IsInf(scale, 1) {
+		scale = 1
+	}
+	// center the drawing
+	offsetX := options.Margin + (w-scale*(maxX-minX))/2
+	offsetY := options.Margin + (h-scale*(maxY-minY))/2
+	return func(p m.Vector) (float64, float64) {
+		x := offsetX + scale*(float64(p.X)-minX)
+		y := offsetY + scale*(maxY-float64(p.Y))
+		return x, y
+	}
+}
+
+func svgPoints(points []m.Vector, project func(m.Vector) (float64, float64)) string {
+	coords := make([]string, len(points))
+	for i, p := range points {
+		x, y := project(p)
+		coords[i] = svgFloat(x) + "," + svgFloat(y)
+	}
+	return strings.Join(coords, " ")
+}
+
+// svgFloat rounds to 3 decimals, plenty for screens and plotters
+func svgFloat(f float64) string {
+	s := fmt.Sprintf("%.3f", f)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
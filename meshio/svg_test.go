@@ -0,0 +1,71 @@
+package meshio
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/deosjr/GenGeo/gen"
+)
+
+func TestWriteSVG(t *testing.T) {
+	l := gen.Lsystem{Axiom: "F[FFF]F"}
+	segments := l.Evaluate(0, 1, 1, math.Pi/2)
+	var buf bytes.Buffer
+	options := SVGOptions{StrokeWidth: func(depth int) float64 { return 2 / float64(depth+1) }}
+	if err := WriteSVG(&buf, segments, options); err != nil {
+		t.Fatal(err)
+	}
+	// y from 0 to 4 fits 496 pixels between the margins, centered in x
+	want := `<svg xmlns="http://www.w3.org/2000/svg" width="512" height="512" viewBox="0 0 512 512">
+<g fill="none" stroke="black" stroke-linecap="round" stroke-linejoin="round">
+<polyline points="256,504 256,380" stroke-width="2"/>
+<polyline points="256,380 256,256 256,132 256,8" stroke-width="1"/>
+<polyline points="256,380 256,256" stroke-width="2"/>
+</g>
+</svg>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := WriteSVG(&buf, segments, SVGOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(buf.String(), "<polyline"); got != 2 {
+		t.Errorf("got %d polylines without stroke width per depth, want 2", got)
+	}
+}
+
+func TestWriteSVGLeaf(t *testing.T) {
+	l := gen.Lsystem{Axiom: "F{+F-F-F}"}
+	var buf bytes.Buffer
+	if err := WriteSVG(&buf, l.Evaluate(0, 1, 1, math.Pi/2), SVGOptions{Fill: "red", Background: "white"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<rect width="100%" height="100%" fill="white"/>`, `<polygon points="`, `fill="red"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %s in\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWriteSVGEscapes(t *testing.T) {
+	l := gen.Lsystem{Axiom: "F{+F-F-F}"}
+	var buf bytes.Buffer
+	options := SVGOptions{Stroke: `"a&b"`, Fill: "<x>", Background: `'`}
+	if err := WriteSVG(&buf, l.Evaluate(0, 1, 1, math.Pi/2), options); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`stroke="&#34;a&amp;b&#34;"`, `fill="&lt;x&gt;"`, `fill="&#39;"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %s in\n%s", want, buf.String())
+		}
+	}
+	if err := xml.Unmarshal(buf.Bytes(), new(struct{})); err != nil {
+		t.Errorf("invalid svg: %v", err)
+	}
+}
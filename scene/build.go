@@ -0,0 +1,314 @@
+package scene
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GRayT/src/render"
+	"github.com/deosjr/GenGeo/gen"
+	"github.com/deosjr/GenGeo/meshio"
+)
+
+// the l-system examples in gen by name
+var lsystemPresets = map[string]func(n int) []gen.Lsegment{
+	"QuadraticKochIsland":  gen.QuadraticKochIsland,
+	"DragonCurve":          gen.DragonCurve,
+	"HexagonalGosperCurve": gen.HexagonalGosperCurve,
+	"PeanoCurve":           gen.PeanoCurve,
+	"HilbertCurve3D":       gen.HilbertCurve3D,
+	"Branch2D_a":           gen.Branch2D_a,
+	"Branch2D_b":           gen.Branch2D_b,
+	"Branch2D_d":           gen.Branch2D_d,
+	"Branch3D":             gen.Branch3D,
+	"Branch3D_2":           gen.Branch3D_2,
+}
+
+var grayScottChannels = map[string]gen.GrayScottChannel{
+	"":   gen.ChannelB,
+	"b":  gen.ChannelB,
+	"a":  gen.ChannelA,
+	"ab": gen.ChannelAB,
+}
+
+// GRayT numbers its tracer types from WhittedStyle up to lastTracerType
+const lastTracerType = m.WhittedStyle + 1
+
+// ParseTracer parses "whitted" or the number of a GRayT tracer type
+func ParseTracer(s string) (m.TracerType, error) {
+	if s == "whitted" {
+		return m.WhittedStyle, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < int(m.WhittedStyle) || n > int(lastTracerType) {
+		return 0, fmt.Errorf("Unknown tracer type: %q", s)
+	}
+	return m.TracerType(n), nil
+}
+
+// Build generates all objects in the scene and returns
+// the render parameters for it, including the scene itself
+// NOTE: sets the GRayT background colour, which is global
+func (d Description) Build() (render.Params, error) {
+	camera := m.NewPerspectiveCamera(d.Render.Width, d.Render.Height, d.Camera.FOV*math.Pi/180.0)
+	scene := m.NewScene(camera)
+
+	for _, l := range d.Lights {
+		c := rgb(l.Color)
+		switch l.Type {
+		case "distant":
+			scene.AddLights(m.NewDistantLight(vector(l.Direction), c, l.Intensity))
+		case "point":
+			scene.AddLights(m.NewPointLight(vector(l.Position), c, l.Intensity))
+		}
+	}
+	if d.Background != nil {
+		m.SetBackgroundColor(rgb(d.Background))
+	}
+
+	materials := map[string]m.Material{}
+	for name, mat := range d.Materials {
+		material, err := d.buildMaterial(mat)
+		if err != nil {
+			return render.Params{}, fmt.Errorf("Material %q: %s", name, err)
+		}
+		materials[name] = material
+	}
+	for i, o := range d.Objects {
+		objects, err := d.buildObject(o, materials[o.Material])
+		if err != nil {
+			return render.Params{}, fmt.Errorf("Object %d: %s", i, err)
+		}
+		if len(o.Transform) > 0 {
+			t := transform(o.Transform)
+			for j, obj := range objects {
+				objects[j] = m.NewSharedObject(obj, t)
+			}
+		}
+		scene.Add(objects...)
+	}
+	scene.Precompute()
+
+	camera.LookAt(vector(d.Camera.From), vector(d.Camera.To), vector(*d.Camera.Up))
+	tracer, err := ParseTracer(d.Render.Tracer)
+	if err != nil {
+		return render.Params{}, err
+	}
+	return render.Params{
+		Scene:        scene,
+		NumWorkers:   d.Render.Workers,
+		NumSamples:   d.Render.Samples,
+		AntiAliasing: *d.Render.AntiAliasing,
+		TracerType:   tracer,
+	}, nil
+}
+
+// path resolves a file path in the scene relative to the scene file
+func (d Description) path(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(d.dir, file)
+}
+
+func (d Description) buildMaterial(mat Material) (m.Material, error) {
+	if mat.Color != nil {
+		return m.NewDiffuseMaterial(m.ConstantTexture{Color: rgb(mat.Color)}), nil
+	}
+	t := mat.Texture
+	switch t.Type {
+	case "grayscott":
+		result := gen.RunGrayScott(gen.GrayScottInput{
+			Width:      t.Width,
+			Height:     t.Height,
+			Iterations: t.Iterations,
+			FeedRate:   t.FeedRate,
+			KillRate:   t.KillRate,
+			DiffRateA:  t.DiffRateA,
+			DiffRateB:  t.DiffRateB,
+			Seed:       t.Seed,
+		})
+		img := result.Image(grayScottChannels[t.Channel], ramp(t.Ramp))
+		return m.NewDiffuseMaterial(m.NewImageTexture(img, m.TriangleMeshUVFunc)), nil
+	case "perlin", "fbm", "turbulence", "worley", "marble", "wood", "warp":
+		img := noise(*t)
+		return m.NewDiffuseMaterial(m.NewImageTexture(img, m.TriangleMeshUVFunc)), nil
+	case "image":
+		file, err := os.Open(d.path(t.File))
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		img, err := png.Decode(file)
+		if err != nil {
+			return nil, err
+		}
+		return m.NewDiffuseMaterial(m.NewImageTexture(img, m.TriangleMeshUVFunc)), nil
+	case "checkerboard":
+		return m.NewDiffuseMaterial(m.NewCheckerboardTexture(t.Squares, m.TriangleMeshUVFunc)), nil
+	default:
+		return m.NewDiffuseMaterial(m.NewUVTexture(m.TriangleMeshUVFunc)), nil
+	}
+}
+
+func noise(t Texture) image.Image {
+	input := gen.NoiseInput{
+		Width:       t.Width,
+		Height:      t.Height,
+		Scale:       t.Scale,
+		Octaves:     t.Octaves,
+		Persistence: t.Persistence,
+		Seed:        t.Seed,
+		Ramp:        ramp(t.Ramp),
+	}
+	switch t.Type {
+	case "perlin":
+		return gen.PerlinNoise(input)
+	case "turbulence":
+		return gen.Turbulence(input)
+	case "worley":
+		return gen.Worley(input)
+	case "marble":
+		return gen.Marble(input, t.Count, t.Strength)
+	case "wood":
+		return gen.Wood(input, t.Count, t.Strength)
+	case "warp":
+		return gen.DomainWarp(input, t.Strength)
+	}
+	return gen.FBM(input)
+}
+
+func ramp(stops []RampStop) gen.ColorRamp {
+	var ramp gen.ColorRamp
+	for _, stop := range stops {
+		c := stop.Color
+		ramp = append(ramp, gen.ColorStop{At: stop.At, Color: color.RGBA{c[0], c[1], c[2], 255}})
+	}
+	return ramp
+}
+
+func (d Description) buildObject(o Object, mat m.Material) ([]m.Object, error) {
+	switch o.Type {
+	case "patches":
+		patches, err := meshio.LoadPatches(d.path(o.File))
+		if err != nil {
+			return nil, err
+		}
+		objects := make([]m.Object, len(patches))
+		for i, p := range patches {
+			objects[i] = p.TriangulateWithNormalMapping(o.Tessellation, mat)
+		}
+		return objects, nil
+	case "lsystem":
+		return []m.Object{buildLsystem(o, mat)}, nil
+	case "extrusion":
+		points := vectors(o.Points)
+		return []m.Object{gen.ExtrudeSolidFace(points, vector(o.Vector), mat)}, nil
+	case "tube":
+		radial := gen.NewRadialCircleConstantRadius(o.Radius, o.Sides)
+		if o.Curve == "helix" {
+			a, b := o.HelixRadius, o.HelixSlope
+			helix := gen.NewHelix(func(float64) float64 { return a }, func(float64) float64 { return b })
+			stepSize := o.StepSize
+			if stepSize == 0 {
+				stepSize = 0.1
+			}
+			return []m.Object{gen.NewParametricObject(helix, radial, o.Steps, stepSize, mat).Build()}, nil
+		}
+		spline, err := gen.NewCatmullRomSpline(vectors(o.Points), gen.CatmullRomCentripetal)
+		if err != nil {
+			return nil, err
+		}
+		stepSize := 1.0 / float64(o.Steps-1)
+		return []m.Object{gen.NewParametricObject(spline, radial, o.Steps, stepSize, mat).Build()}, nil
+	case "sphere":
+		s := gen.NewSphere(vector(o.Center), o.Radius)
+		if o.Smooth {
+			return []m.Object{s.NormalMappedSphere(mat, o.Subdivisions)}, nil
+		}
+		return []m.Object{m.NewTriangleComplexObject(s.Triangulate(o.Subdivisions, mat))}, nil
+	}
+	return nil, fmt.Errorf("unknown type: %q", o.Type)
+}
+
+// buildLsystem draws branches as tubes and leaves as flat polygons
+func buildLsystem(o Object, mat m.Material) m.Object {
+	var segments []gen.Lsegment
+	if o.Preset != "" {
+		segments = lsystemPresets[o.Preset](o.Iterations)
+	} else {
+		l := gen.Lsystem{Axiom: o.Axiom, Productions: map[rune]string{}}
+		for k, v := range o.Productions {
+			l.Productions[[]rune(k)[0]] = v
+		}
+		segments = l.Evaluate(o.Iterations, o.Length, o.LengthFactor, o.Angle*math.Pi/180.0)
+	}
+	radial := gen.NewRadialCircleConstantRadius(o.Radius, o.Sides)
+	objects := []m.Object{}
+	for _, s := range segments {
+		points := s.GetPoints()
+		switch s.(type) {
+		case gen.Lleaf:
+			if len(points) >= 3 {
+				objects = append(objects, m.NewTriangleComplexObject(gen.TriangulateConvexPolygon(points, mat)))
+			}
+		default:
+			objects = append(objects, gen.BuildFromPoints(radial, points, mat))
+		}
+	}
+	return m.NewComplexObject(objects)
+}
+
+// transform combines a list of transforms, applying them first to last
+func transform(list []Transform) m.Transform {
+	var t m.Transform
+	for i, tt := range list {
+		var next m.Transform
+		switch {
+		case tt.Translate != nil:
+			next = m.Translate(vector(*tt.Translate))
+		case tt.Scale != nil:
+			next = m.Scale(tt.Scale[0], tt.Scale[1], tt.Scale[2])
+		case tt.RotateX != nil:
+			next = m.RotateX(*tt.RotateX * math.Pi / 180.0)
+		case tt.RotateY != nil:
+			next = m.RotateY(*tt.RotateY * math.Pi / 180.0)
+		case tt.RotateZ != nil:
+			next = m.RotateZ(*tt.RotateZ * math.Pi / 180.0)
+		case tt.Rotate != nil:
+			next = m.Rotate(tt.Rotate.Angle*math.Pi/180.0, vector(tt.Rotate.Axis))
+		}
+		if i == 0 {
+			t = next
+			continue
+		}
+		t = next.Mul(t)
+	}
+	return t
+}
+
+func vector(v [3]float32) m.Vector {
+	return m.Vector{v[0], v[1], v[2]}
+}
+
+func vectors(list [][3]float32) []m.Vector {
+	points := make([]m.Vector, len(list))
+	for i, p := range list {
+		points[i] = vector(p)
+	}
+	return points
+}
+
+// rgb defaults to white
+func rgb(c *[3]uint8) m.Color {
+	if c == nil {
+		return m.NewColor(255, 255, 255)
+	}
+	return m.NewColor(c[0], c[1], c[2])
+}
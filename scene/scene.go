@@ -0,0 +1,389 @@
+// Package scene reads scenes of generated geometry from json files
+// and builds them into GRayT scenes ready to render
+package scene
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// maxNoiseScale bounds the cells across noise textures: perlin noise
+// repeats beyond its 256 lattice cells and worley keeps a point per cell
+const maxNoiseScale = 256
+
+// Description is the contents of a scene file.
+// angles are in degrees and colours are 0-255 rgb triples.
+// input files are relative to the directory of the scene file,
+// the output file to the working directory
+type Description struct {
+	Render     RenderSettings      `json:"render"`
+	Camera     Camera              `json:"camera"`
+	Background *[3]uint8           `json:"background"`
+	Lights     []Light             `json:"lights"`
+	Materials  map[string]Material `json:"materials"`
+	Objects    []Object            `json:"objects"`
+
+	// directory file paths are relative to
+	dir string
+}
+
+type RenderSettings struct {
+	Width        uint  `json:"width"`
+	Height       uint  `json:"height"`
+	Workers      int   `json:"workers"`
+	Samples      int   `json:"samples"`
+	AntiAliasing *bool `json:"antialiasing"`
+	// whitted, or the number of another GRayT tracer type
+	Tracer string `json:"tracer"`
+	Output string `json:"output"`
+}
+
+type Camera struct {
+	From [3]float32  `json:"from"`
+	To   [3]float32  `json:"to"`
+	Up   *[3]float32 `json:"up"`
+	// horizontal field of view
+	FOV float64 `json:"fov"`
+}
+
+// Light is a distant light shining in Direction
+// or a point light at Position
+type Light struct {
+	Type      string     `json:"type"`
+	Direction [3]float32 `json:"direction"`
+	Position  [3]float32 `json:"position"`
+	Color     *[3]uint8  `json:"color"`
+	Intensity float32    `json:"intensity"`
+}
+
+// Material is diffuse, with either a single colour or a texture
+type Material struct {
+	Color   *[3]uint8 `json:"color"`
+	Texture *Texture  `json:"texture"`
+}
+
+// Texture is one of
+// grayscott: a Gray-Scott reaction-diffusion pattern, optionally
+// drawn through a ramp of stops in increasing order,
+// perlin, fbm, turbulence, worley, marble, wood, warp: tileable noise,
+// see the functions of those names in gen,
+// image: a png file,
+// checkerboard: a checkerboard with Squares squares per side,
+// uv: the uv coordinates as colours
+type Texture struct {
+	Type string `json:"type"`
+
+	// grayscott and noise
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	Iterations int     `json:"iterations"`
+	FeedRate   float64 `json:"feedRate"`
+	KillRate   float64 `json:"killRate"`
+	DiffRateA  float64 `json:"diffRateA"`
+	DiffRateB  float64 `json:"diffRateB"`
+	Seed       int64   `json:"seed"`
+	// a, b (default) or ab, see gen.GrayScottChannel
+	Channel string `json:"channel"`
+	// colour gradient over the values, in grey if empty
+	Ramp []RampStop `json:"ramp"`
+
+	// noise
+	Scale       int     `json:"scale"`
+	Octaves     int     `json:"octaves"`
+	Persistence float64 `json:"persistence"`
+	// stripes of marble or rings of wood
+	Count int `json:"count"`
+	// turbulence of marble and wood, amount of warp
+	Strength float64 `json:"strength"`
+
+	// image
+	File string `json:"file"`
+
+	// checkerboard
+	Squares int `json:"squares"`
+}
+
+// RampStop is the colour of a gradient at a value
+type RampStop struct {
+	At    float64  `json:"at"`
+	Color [3]uint8 `json:"color"`
+}
+
+// Object is one of the generators in package gen, see the fields per type:
+// patches: bicubic bezier patches from File, each triangulated into
+// Tessellation*Tessellation*2 triangles;
+// lsystem: an l-system given by Axiom and Productions, or one of the
+// examples in gen by Preset, drawn as tubes of Radius with Sides sides,
+// leaves as flat polygons;
+// extrusion: the polygon Points extruded along Vector;
+// tube: a tube of Radius with Sides sides along a helix (HelixRadius,
+// HelixSlope) or a catmull-rom spline through Points, sampled Steps times;
+// sphere: a sphere at Center with Radius, subdivided Subdivisions times
+type Object struct {
+	Type      string      `json:"type"`
+	Material  string      `json:"material"`
+	Transform []Transform `json:"transform"`
+
+	// patches
+	File         string `json:"file"`
+	Tessellation int    `json:"tessellation"`
+
+	// lsystem
+	Axiom        string            `json:"axiom"`
+	Productions  map[string]string `json:"productions"`
+	Preset       string            `json:"preset"`
+	Iterations   int               `json:"iterations"`
+	Length       float32           `json:"length"`
+	LengthFactor float64           `json:"lengthFactor"`
+	Angle        float64           `json:"angle"`
+
+	// lsystem, extrusion and tube
+	Points [][3]float32 `json:"points"`
+	Radius float32      `json:"radius"`
+	Sides  int          `json:"sides"`
+
+	// extrusion
+	Vector [3]float32 `json:"vector"`
+
+	// tube
+	Curve       string  `json:"curve"`
+	HelixRadius float64 `json:"helixRadius"`
+	HelixSlope  float64 `json:"helixSlope"`
+	Steps       int     `json:"steps"`
+	StepSize    float64 `json:"stepSize"`
+
+	// sphere
+	Center       [3]float32 `json:"center"`
+	Subdivisions int        `json:"subdivisions"`
+	Smooth       bool       `json:"smooth"`
+}
+
+// Transform is a single translation, rotation or scaling.
+// a list of transforms is applied in order, first to last
+type Transform struct {
+	Translate *[3]float32 `json:"translate"`
+	Scale     *[3]float32 `json:"scale"`
+	RotateX   *float64    `json:"rotateX"`
+	RotateY   *float64    `json:"rotateY"`
+	RotateZ   *float64    `json:"rotateZ"`
+	// rotation of Angle degrees around Axis
+	Rotate *struct {
+		Axis  [3]float32 `json:"axis"`
+		Angle float64    `json:"angle"`
+	} `json:"rotate"`
+}
+
+// Load reads a scene file; relative paths in it are resolved
+// against the directory of the file
+func Load(filename string) (Description, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Description{}, err
+	}
+	defer file.Close()
+	d, err := Read(file)
+	if err != nil {
+		return Description{}, fmt.Errorf("%s: %s", filename, err)
+	}
+	d.dir = filepath.Dir(filename)
+	return d, nil
+}
+
+// Read parses and checks a scene description. unknown fields are an error,
+// missing render settings get the defaults main.go used to hard-code
+func Read(r io.Reader) (Description, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	d := Description{}
+	if err := dec.Decode(&d); err != nil {
+		return Description{}, err
+	}
+	d.setDefaults()
+	if err := d.validate(); err != nil {
+		return Description{}, err
+	}
+	return d, nil
+}
+
+func (d *Description) setDefaults() {
+	r := &d.Render
+	if r.Width == 0 {
+		r.Width = 1600
+	}
+	if r.Height == 0 {
+		r.Height = 1200
+	}
+	if r.Workers == 0 {
+		r.Workers = 10
+	}
+	if r.Samples == 0 {
+		r.Samples = 10
+	}
+	if r.AntiAliasing == nil {
+		aa := true
+		r.AntiAliasing = &aa
+	}
+	if r.Tracer == "" {
+		r.Tracer = "whitted"
+	}
+	if r.Output == "" {
+		r.Output = "out.png"
+	}
+	if d.Camera.FOV == 0 {
+		d.Camera.FOV = 90
+	}
+	if d.Camera.Up == nil {
+		d.Camera.Up = &[3]float32{0, 1, 0}
+	}
+	for i := range d.Objects {
+		o := &d.Objects[i]
+		if o.Tessellation == 0 {
+			o.Tessellation = 32
+		}
+		if o.Sides == 0 {
+			o.Sides = 8
+		}
+	}
+}
+
+func (d Description) validate() error {
+	if d.Render.Workers < 0 || d.Render.Samples < 0 {
+		return fmt.Errorf("Invalid render settings: %d workers, %d samples", d.Render.Workers, d.Render.Samples)
+	}
+	if _, err := ParseTracer(d.Render.Tracer); err != nil {
+		return err
+	}
+	if d.Camera.From == d.Camera.To {
+		return fmt.Errorf("Camera looks from and to the same point")
+	}
+	for i, l := range d.Lights {
+		if l.Type != "distant" && l.Type != "point" {
+			return fmt.Errorf("Light %d: unknown type: %q", i, l.Type)
+		}
+	}
+	for name, mat := range d.Materials {
+		if (mat.Color == nil) == (mat.Texture == nil) {
+			return fmt.Errorf("Material %q: needs either a color or a texture", name)
+		}
+		if t := mat.Texture; t != nil {
+			for i := 1; i < len(t.Ramp); i++ {
+				if t.Ramp[i].At < t.Ramp[i-1].At {
+					return fmt.Errorf("Material %q: ramp stops out of order", name)
+				}
+			}
+			switch t.Type {
+			case "grayscott":
+				if t.Width < 1 || t.Height < 1 {
+					return fmt.Errorf("Material %q: grayscott texture without size", name)
+				}
+				if _, ok := grayScottChannels[t.Channel]; !ok {
+					return fmt.Errorf("Material %q: unknown channel: %q", name, t.Channel)
+				}
+			case "perlin", "fbm", "turbulence", "worley", "marble", "wood", "warp":
+				if t.Width < 1 || t.Height < 1 {
+					return fmt.Errorf("Material %q: noise texture without size", name)
+				}
+				if t.Scale < 0 || t.Scale > maxNoiseScale {
+					return fmt.Errorf("Material %q: scale should be between 0 and %d, got %d", name, maxNoiseScale, t.Scale)
+				}
+				if t.Octaves < 0 {
+					return fmt.Errorf("Material %q: negative octaves: %d", name, t.Octaves)
+				}
+			case "uv":
+			case "image":
+				if t.File == "" {
+					return fmt.Errorf("Material %q: image texture without file", name)
+				}
+			case "checkerboard":
+				if t.Squares < 1 {
+					return fmt.Errorf("Material %q: checkerboard without squares", name)
+				}
+			default:
+				return fmt.Errorf("Material %q: unknown texture type: %q", name, t.Type)
+			}
+		}
+	}
+	for i, o := range d.Objects {
+		if err := d.validateObject(o); err != nil {
+			return fmt.Errorf("Object %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+func (d Description) validateObject(o Object) error {
+	if _, ok := d.Materials[o.Material]; !ok {
+		return fmt.Errorf("unknown material: %q", o.Material)
+	}
+	for _, t := range o.Transform {
+		n := 0
+		for _, set := range []bool{t.Translate != nil, t.Scale != nil, t.RotateX != nil, t.RotateY != nil, t.RotateZ != nil, t.Rotate != nil} {
+			if set {
+				n++
+			}
+		}
+		if n != 1 {
+			return fmt.Errorf("transform needs exactly one operation, got %d", n)
+		}
+	}
+	if o.Tessellation < 1 {
+		return fmt.Errorf("tessellation should be at least 1, got %d", o.Tessellation)
+	}
+	if o.Sides < 3 {
+		return fmt.Errorf("sides should be at least 3, got %d", o.Sides)
+	}
+	if o.Subdivisions < 0 {
+		return fmt.Errorf("negative subdivisions: %d", o.Subdivisions)
+	}
+	if o.Iterations < 0 {
+		return fmt.Errorf("negative iterations: %d", o.Iterations)
+	}
+	switch o.Type {
+	case "patches":
+		if o.File == "" {
+			return fmt.Errorf("patches without file")
+		}
+	case "lsystem":
+		if (o.Preset == "") == (o.Axiom == "") {
+			return fmt.Errorf("lsystem needs either a preset or an axiom")
+		}
+		if _, ok := lsystemPresets[o.Preset]; o.Preset != "" && !ok {
+			return fmt.Errorf("unknown lsystem preset: %q", o.Preset)
+		}
+		for k := range o.Productions {
+			if len([]rune(k)) != 1 {
+				return fmt.Errorf("production for more than one symbol: %q", k)
+			}
+		}
+		if o.Radius <= 0 {
+			return fmt.Errorf("lsystem without radius")
+		}
+	case "extrusion":
+		if len(o.Points) < 3 {
+			return fmt.Errorf("extrusion needs at least 3 points, got %d", len(o.Points))
+		}
+	case "tube":
+		switch o.Curve {
+		case "helix":
+		case "catmullrom":
+			if len(o.Points) < 2 {
+				return fmt.Errorf("catmullrom tube needs at least 2 points, got %d", len(o.Points))
+			}
+		default:
+			return fmt.Errorf("unknown curve: %q", o.Curve)
+		}
+		if o.Radius <= 0 || o.Steps < 2 {
+			return fmt.Errorf("tube needs a radius and at least 2 steps")
+		}
+	case "sphere":
+		if o.Radius <= 0 {
+			return fmt.Errorf("sphere without radius")
+		}
+	default:
+		return fmt.Errorf("unknown type: %q", o.Type)
+	}
+	return nil
+}
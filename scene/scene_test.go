@@ -0,0 +1,115 @@
+package scene
+
+import (
+	"strings"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestLoadExample(t *testing.T) {
+	d, err := Load("../scenes/teapot.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Objects) != 1 || d.Objects[0].Tessellation != 32 || len(d.Objects[0].Transform) != 3 {
+		t.Errorf("got objects %v", d.Objects)
+	}
+	if d.path(d.Objects[0].File) != "../teapot" {
+		t.Errorf("got path %q", d.path(d.Objects[0].File))
+	}
+}
+
+const testScene = `{
+  "render": {"width": 16, "height": 12, "samples": 1},
+  "camera": {"from": [0, 0, -5], "to": [0, 0, 0]},
+  "lights": [{"type": "point", "position": [0, 5, 0], "intensity": 100}],
+  "materials": {
+    "red": {"color": [255, 0, 0]},
+    "checkers": {"texture": {"type": "checkerboard", "squares": 4}}
+  },
+  "objects": [
+    {"type": "sphere", "center": [0, 0, 0], "radius": 1, "subdivisions": 2, "smooth": true, "material": "red"},
+    {"type": "lsystem", "preset": "Branch2D_a", "iterations": 2, "radius": 0.05, "material": "red",
+     "transform": [{"scale": [0.1, 0.1, 0.1]}, {"rotate": {"axis": [0, 0, 1], "angle": 45}}]},
+    {"type": "lsystem", "axiom": "F", "productions": {"F": "F[+F]F"}, "iterations": 2,
+     "length": 1, "lengthFactor": 0.5, "angle": 30, "radius": 0.05, "material": "red"},
+    {"type": "extrusion", "points": [[0, 0, 0], [1, 0, 0], [1, 1, 0], [0, 1, 0]], "vector": [0, 0, 1], "material": "checkers"},
+    {"type": "tube", "curve": "helix", "helixRadius": 1, "helixSlope": 0.2, "steps": 20, "radius": 0.1, "material": "red"},
+    {"type": "tube", "curve": "catmullrom", "points": [[0, 0, 0], [1, 1, 0], [2, 0, 1]], "steps": 10, "radius": 0.1, "material": "red"}
+  ]
+}`
+
+func TestBuild(t *testing.T) {
+	d, err := Read(strings.NewReader(testScene))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Render.Workers != 10 || d.Render.Output != "out.png" || *d.Camera.Up != [3]float32{0, 1, 0} {
+		t.Errorf("defaults not set: %v %v", d.Render, d.Camera)
+	}
+	params, err := d.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Scene == nil || params.NumSamples != 1 || params.TracerType != m.WhittedStyle || !params.AntiAliasing {
+		t.Errorf("got params %v", params)
+	}
+}
+
+func TestTransformOrder(t *testing.T) {
+	translate := [3]float32{1, 0, 0}
+	angle := 90.0
+	tr := transform([]Transform{{Translate: &translate}, {RotateZ: &angle}})
+	// translated first to (1,0,0), then rotated to (0,1,0)
+	got := tr.Point(m.Vector{})
+	if got.X*got.X > 1e-10 || (got.Y-1)*(got.Y-1) > 1e-10 {
+		t.Errorf("got %v want (0,1,0)", got)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	for i, tt := range []struct {
+		input string
+		err   string
+	}{
+		{`{"camera": {"from": [0, 0, 1]}, "unknown": 1}`, "json: unknown field"},
+		{`{"camera": {"from": [0, 0, 1]}, "render": {"tracer": "magic"}}`, "Unknown tracer type"},
+		{`{"camera": {"from": [0, 0, 1]}, "render": {"tracer": "-3"}}`, `Unknown tracer type: "-3"`},
+		{`{"camera": {}}`, "Camera looks from and to the same point"},
+		{`{"camera": {"from": [0, 0, 1]}, "lights": [{"type": "spot"}]}`, "Light 0: unknown type"},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {}}}`, `Material "a": needs either`},
+		{`{"camera": {"from": [0, 0, 1]}, "objects": [{"type": "sphere", "radius": 1, "material": "b"}]}`, `Object 0: unknown material: "b"`},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"color": [1, 2, 3]}}, "objects": [{"type": "cube", "material": "a"}]}`, `Object 0: unknown type: "cube"`},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"texture": {"type": "grayscott", "width": 8}}}}`, `Material "a": grayscott texture without size`},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"texture": {"type": "grayscott", "width": 8, "height": 8, "channel": "c"}}}}`, `Material "a": unknown channel: "c"`},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"texture": {"type": "grayscott", "width": 8, "height": 8, "ramp": [{"at": 1}, {"at": 0}]}}}}`, `Material "a": ramp stops out of order`},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"texture": {"type": "marble", "width": 8}}}}`, `Material "a": noise texture without size`},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"texture": {"type": "fbm", "width": 8, "height": 8, "octaves": -1}}}}`, `Material "a": negative octaves: -1`},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"texture": {"type": "worley", "width": 8, "height": 8, "scale": 100000}}}}`, `Material "a": scale should be between 0 and 256, got 100000`},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"color": [1, 2, 3]}}, "objects": [{"type": "sphere", "radius": 1, "material": "a", "transform": [{}]}]}`, "Object 0: transform needs exactly one operation"},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"color": [1, 2, 3]}}, "objects": [{"type": "lsystem", "preset": "Tree", "radius": 1, "material": "a"}]}`, "Object 0: unknown lsystem preset"},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"color": [1, 2, 3]}}, "objects": [{"type": "patches", "file": "a.txt", "tessellation": -1, "material": "a"}]}`, "Object 0: tessellation should be at least 1, got -1"},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"color": [1, 2, 3]}}, "objects": [{"type": "tube", "curve": "helix", "radius": 1, "steps": 2, "sides": 2, "material": "a"}]}`, "Object 0: sides should be at least 3, got 2"},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"color": [1, 2, 3]}}, "objects": [{"type": "sphere", "radius": 1, "subdivisions": -1, "material": "a"}]}`, "Object 0: negative subdivisions: -1"},
+		{`{"camera": {"from": [0, 0, 1]}, "materials": {"a": {"color": [1, 2, 3]}}, "objects": [{"type": "lsystem", "axiom": "F", "radius": 1, "iterations": -2, "material": "a"}]}`, "Object 0: negative iterations: -2"},
+	} {
+		_, err := Read(strings.NewReader(tt.input))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("%d): got error %v want %q", i, err, tt.err)
+		}
+	}
+}
+
+func TestParseTracer(t *testing.T) {
+	for _, s := range []string{"whitted", "0", "1"} {
+		if _, err := ParseTracer(s); err != nil {
+			t.Errorf("%q: %v", s, err)
+		}
+	}
+	for _, s := range []string{"99", "-3", "path"} {
+		if _, err := ParseTracer(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}